@@ -0,0 +1,375 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	units "github.com/docker/go-units"
+
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/quota"
+	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/store"
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+func runCheckConfig(args []string) error {
+	fs := flag.NewFlagSet("check-config", flag.ExitOnError)
+	fs.Usage = usage(fs, "check-config [flags]", "Validate the configuration and exit.")
+
+	cfgFile := configFlag(fs)
+	fs.Parse(args)
+
+	cfg, err := loadConfig(*cfgFile)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Dump(os.Stdout); err != nil {
+		return err
+	}
+
+	fmt.Printf("Configuration is valid (%d servers)\n", len(cfg.Servers))
+
+	return nil
+}
+
+func runSetup(args []string) error {
+	fs := flag.NewFlagSet("setup", flag.ExitOnError)
+	fs.Usage = usage(fs, "setup [flags]", "Create the buckets and set up their CORS and lifecycle rules. Changes of the rules are shown as a diff.")
+
+	cfgFile := configFlag(fs)
+	svrID := fs.String("server", "", "ID of the server (default: all servers)")
+	dryRun := fs.Bool("dry-run", false, "only show the changes")
+	fs.Parse(args)
+
+	_, svrs, err := loadServers(*cfgFile, *svrID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	for _, svr := range svrs {
+		diff, err := svr.SetupDiff(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(diff) == 0 {
+			fmt.Printf("Server %s: no changes\n", svr.Config.ID)
+		} else {
+			fmt.Printf("Server %s:\n", svr.Config.ID)
+			for _, line := range diff {
+				fmt.Println("  " + line)
+			}
+		}
+
+		if *dryRun {
+			continue
+		}
+
+		if err := svr.Setup(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runList(args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	fs.Usage = usage(fs, "ls [flags]", "List uploaded files and their meta-data.")
+
+	cfgFile := configFlag(fs)
+	svrID := fs.String("server", "", "ID of the server (default: all servers)")
+	uploads := fs.Bool("uploads", false, "list incomplete uploads instead")
+	asJSON := fs.Bool("json", false, "print one JSON object per line")
+	fs.Parse(args)
+
+	_, svrs, err := loadServers(*cfgFile, *svrID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	enc := json.NewEncoder(os.Stdout)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	if *uploads {
+		if !*asJSON {
+			fmt.Fprintln(tw, "SERVER\tKEY\tUPLOAD ID\tINITIATED")
+		}
+
+		for _, svr := range svrs {
+			ups, err := svr.ListUploads(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list uploads of server %s: %w", svr.Config.ID, err)
+			}
+
+			for _, u := range ups {
+				if *asJSON {
+					enc.Encode(struct {
+						Server string `json:"server"`
+						server.Upload
+					}{svr.Config.ID, u})
+				} else {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", svr.Config.ID, u.Key, u.UploadID, u.Initiated.Format(time.RFC3339))
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if !*asJSON {
+		fmt.Fprintln(tw, "SERVER\tKEY\tSIZE\tMODIFIED\tEXPIRATION\tUPLOADER\tFILENAME")
+	}
+
+	for _, svr := range svrs {
+		var token string
+		for {
+			keys, next, err := svr.ListObjectKeys(ctx, token, 1000)
+			if err != nil {
+				return fmt.Errorf("failed to list objects of server %s: %w", svr.Config.ID, err)
+			}
+
+			for _, key := range keys {
+				// Skip GoSƐ's own state and foreign objects.
+				if !utils.IsValidETag(key) {
+					continue
+				}
+
+				obj, err := svr.GetObjectInfo(ctx, key)
+				if server.IsNotFound(err) {
+					continue
+				} else if err != nil {
+					slog.Error("Failed to get object", "server", svr.Config.ID, "key", key, "error", err)
+					continue
+				}
+
+				if *asJSON {
+					enc.Encode(struct {
+						Server string `json:"server"`
+						*server.Object
+					}{svr.Config.ID, obj})
+				} else {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", svr.Config.ID, obj.Key,
+						units.HumanSize(float64(obj.Size)), obj.LastModified.Format(time.RFC3339), obj.Tags["expiration"],
+						obj.Metadata["Original-Uploader"], obj.Metadata["Original-Filename"])
+				}
+			}
+
+			if token = next; token == "" {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func runRemove(args []string) error {
+	fs := flag.NewFlagSet("rm", flag.ExitOnError)
+	fs.Usage = usage(fs, "rm [flags] ETAG...", "Delete uploaded files.")
+
+	cfgFile := configFlag(fs)
+	svrID := fs.String("server", "", "ID of the server (default: all servers)")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, key := range fs.Args() {
+		if !utils.IsValidETag(key) {
+			return fmt.Errorf("invalid etag: %s", key)
+		}
+	}
+
+	cfg, svrs, err := loadServers(*cfgFile, *svrID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	quotas := newQuotas(cfg)
+
+	for _, svr := range svrs {
+		for _, key := range fs.Args() {
+			if err := removeObject(ctx, svr, quotas, key); err != nil {
+				return fmt.Errorf("failed to delete object %s from server %s: %w", key, svr.Config.ID, err)
+			}
+
+			slog.Info("Deleted object", "server", svr.Config.ID, "etag", key)
+		}
+	}
+
+	return nil
+}
+
+func runGC(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	fs.Usage = usage(fs, "gc [flags]", "Abort stale incomplete uploads and delete expired files.")
+
+	cfgFile := configFlag(fs)
+	svrID := fs.String("server", "", "ID of the server (default: all servers)")
+	maxAge := fs.Duration("max-age", 0, "age after which incomplete uploads are aborted (default: session validity)")
+	dryRun := fs.Bool("dry-run", false, "only show what would be removed")
+	fs.Parse(args)
+
+	cfg, svrs, err := loadServers(*cfgFile, *svrID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// Uploads can not be resumed anymore once their session has expired.
+	if *maxAge == 0 {
+		*maxAge = cfg.SessionValidity
+	}
+
+	quotas := newQuotas(cfg)
+	now := time.Now()
+
+	for _, svr := range svrs {
+		logger := slog.With("server", svr.Config.ID, "dry_run", *dryRun)
+
+		stale, err := svr.StaleUploads(ctx, now.Add(-*maxAge))
+		if err != nil {
+			return fmt.Errorf("failed to list uploads of server %s: %w", svr.Config.ID, err)
+		}
+
+		for _, u := range stale {
+			if !*dryRun {
+				if err := svr.AbortUpload(ctx, u.Key, u.UploadID); err != nil {
+					return fmt.Errorf("failed to abort upload %s: %w", u.UploadID, err)
+				}
+			}
+
+			logger.Info("Aborted stale upload", "etag", u.Key, "upload_id", u.UploadID, "initiated", u.Initiated)
+		}
+
+		expired, err := svr.ExpiredObjects(ctx, now)
+		if err != nil {
+			return fmt.Errorf("failed to list expired objects of server %s: %w", svr.Config.ID, err)
+		}
+
+		for _, key := range expired {
+			if !*dryRun {
+				if err := removeObject(ctx, svr, quotas, key); err != nil {
+					return fmt.Errorf("failed to delete object %s: %w", key, err)
+				}
+			}
+
+			logger.Info("Deleted expired object", "etag", key)
+		}
+
+		logger.Info("Garbage collection completed", "aborted_uploads", len(stale), "deleted_objects", len(expired))
+	}
+
+	return nil
+}
+
+// configFlag adds the -config flag shared by the admin commands.
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "", "path to config file")
+}
+
+// loadConfig loads the configuration and sets up logging like the server does.
+func loadConfig(path string) (*config.Config, error) {
+	cfg, err := config.NewConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	logger, err := logging.New(&cfg.Log, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+
+	slog.SetDefault(logger)
+
+	return cfg, nil
+}
+
+// loadServers loads the configuration and returns the server with the given ID or all servers sorted by their ID.
+func loadServers(path, id string) (*config.Config, []server.Server, error) {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	svrs := server.NewList(cfg.Servers)
+
+	if id != "" {
+		svr, ok := svrs[id]
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid server: %s", id)
+		}
+
+		return cfg, []server.Server{svr}, nil
+	}
+
+	list := []server.Server{}
+	for _, id := range slices.Sorted(maps.Keys(svrs)) {
+		list = append(list, svrs[id])
+	}
+
+	return cfg, list, nil
+}
+
+// newQuotas returns the quota manager of the server or nil if quotas are disabled.
+func newQuotas(cfg *config.Config) *quota.Manager {
+	if cfg.Quota == nil {
+		return nil
+	}
+
+	state := store.NewS3(server.NewList(cfg.Servers)[cfg.State.Server], cfg.State.Prefix)
+
+	return quota.NewManager(cfg.Quota, state, cfg.SessionValidity)
+}
+
+// removeObject deletes an object and removes it from the quota of its uploader.
+func removeObject(ctx context.Context, svr server.Server, quotas *quota.Manager, key string) error {
+	var uploader string
+	if quotas != nil {
+		if obj, err := svr.GetObjectInfo(ctx, key); err == nil {
+			uploader = obj.Metadata["Original-Uploader"]
+		}
+	}
+
+	if err := svr.DeleteObjectByKey(ctx, key); err != nil {
+		return err
+	}
+
+	if uploader != "" {
+		if err := quotas.Forget(uploader, quota.Key(svr.Config.ID, key)); err != nil {
+			slog.Warn("Failed to update quota of deleted object", "etag", key, "error", err)
+		}
+	}
+
+	return nil
+}
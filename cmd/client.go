@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	units "github.com/docker/go-units"
+
+	"github.com/stv0g/gose/pkg/client"
+)
+
+// defaultURL is the URL of GoSƐ used by the client commands if neither -url nor GOSE_URL are set.
+const defaultURL = "http://localhost:8080"
+
+func runUpload(args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	fs.Usage = usage(fs, "upload [flags] FILE", "Upload a file and print its download link.")
+
+	baseURL := fs.String("url", envOr("GOSE_URL", defaultURL), "URL of GoSƐ (or GOSE_URL)")
+	opts := &client.UploadOptions{}
+	fs.StringVar(&opts.Server, "server", "", "ID of the server or \"auto\" (default: first available server)")
+	fs.StringVar(&opts.Group, "group", "", "group of servers for the \"auto\" server")
+	fs.StringVar(&opts.Expiration, "expiration", "", "ID of the expiration class (default: first class of the server)")
+	fs.BoolVar(&opts.ShortURL, "short-url", false, "shorten the download link")
+	fs.StringVar(&opts.NotifyMail, "notify-mail", "", "mail address which is notified about the upload")
+	fs.BoolVar(&opts.Encrypt, "encrypt", false, "encrypt the file end-to-end")
+	fs.StringVar(&opts.FileName, "name", "", "file name (default: name of FILE)")
+	fs.StringVar(&opts.Type, "type", "", "MIME type (default: detected from the file name)")
+	concurrency := fs.Int("concurrency", client.DefaultConcurrency, "number of parts which are uploaded in parallel")
+	quiet := fs.Bool("quiet", false, "do not show progress")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	path := fs.Arg(0)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if opts.FileName == "" {
+		opts.FileName = filepath.Base(path)
+	}
+
+	if opts.Type == "" {
+		opts.Type = mime.TypeByExtension(filepath.Ext(opts.FileName))
+	}
+
+	if !*quiet {
+		opts.Progress = showProgress("Uploading")
+	}
+
+	c, err := newClient(*baseURL, *concurrency)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	res, err := c.Upload(ctx, f, fi.Size(), opts)
+	if !*quiet {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if res.Existing && !*quiet {
+		fmt.Fprintln(os.Stderr, "File has already been uploaded before")
+	}
+
+	fmt.Println(res.URL)
+
+	return nil
+}
+
+func runDownload(args []string) error {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	fs.Usage = usage(fs, "download [flags] LINK", "Download a file. Encrypted files are decrypted with the key from the link.")
+
+	output := fs.String("o", "", "output file or - for stdout (default: name of the file in the current directory)")
+	quiet := fs.Bool("quiet", false, "do not show progress")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c, err := newClient(defaultURL, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var progress func(client.Progress)
+	if !*quiet {
+		progress = showProgress("Downloading")
+		defer fmt.Fprintln(os.Stderr)
+	}
+
+	if *output == "-" {
+		_, err := c.Download(ctx, fs.Arg(0), os.Stdout, progress)
+		return err
+	}
+
+	// The name of the file is only known once the download has finished.
+	f, err := os.CreateTemp(filepath.Dir(*output), ".gose-download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	name, err := c.Download(ctx, fs.Arg(0), f, progress)
+	if err := errors.Join(err, f.Close()); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		*output = filepath.Base(name)
+	}
+
+	return os.Rename(f.Name(), *output)
+}
+
+func newClient(baseURL string, concurrency int) (*client.Client, error) {
+	c, err := client.New(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Concurrency = concurrency
+
+	if dir, err := os.UserCacheDir(); err == nil {
+		c.Sessions = fileSessions(filepath.Join(dir, "gose", "sessions"))
+	}
+
+	return c, nil
+}
+
+// fileSessions stores the sessions of uploads in files for resuming them after an interruption.
+type fileSessions string
+
+func (s fileSessions) Get(key string) string {
+	token, _ := os.ReadFile(filepath.Join(string(s), key))
+	return string(token)
+}
+
+func (s fileSessions) Set(key, token string) {
+	if err := os.MkdirAll(string(s), 0o700); err == nil {
+		os.WriteFile(filepath.Join(string(s), key), []byte(token), 0o600)
+	}
+}
+
+func (s fileSessions) Delete(key string) {
+	os.Remove(filepath.Join(string(s), key))
+}
+
+// showProgress returns a callback which prints the progress of a transfer at most every 100ms.
+func showProgress(action string) func(client.Progress) {
+	var last time.Time
+	return func(p client.Progress) {
+		done := p.Transferred + p.Skipped
+		if time.Since(last) < 100*time.Millisecond && done != p.Total {
+			return
+		}
+
+		last = time.Now()
+
+		if p.Total > 0 {
+			fmt.Fprintf(os.Stderr, "\r%s: %s / %s (%d%%)  ", action, units.HumanSize(float64(done)), units.HumanSize(float64(p.Total)), done*100/p.Total)
+		} else {
+			fmt.Fprintf(os.Stderr, "\r%s: %s  ", action, units.HumanSize(float64(done)))
+		}
+	}
+}
+
+func usage(fs *flag.FlagSet, synopsis, description string) func() {
+	return func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: gose %s\n\n%s\n\n", synopsis, description)
+		fs.PrintDefaults()
+	}
+}
+
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+
+	return def
+}
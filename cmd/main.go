@@ -4,17 +4,37 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
+	"github.com/stv0g/gose/pkg/abuse"
+	"github.com/stv0g/gose/pkg/audit"
+	"github.com/stv0g/gose/pkg/ban"
 	"github.com/stv0g/gose/pkg/config"
 	"github.com/stv0g/gose/pkg/handlers"
+	"github.com/stv0g/gose/pkg/health"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/notifier"
+	"github.com/stv0g/gose/pkg/quota"
+	"github.com/stv0g/gose/pkg/ratelimit"
+	"github.com/stv0g/gose/pkg/scanner"
 	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/session"
+	"github.com/stv0g/gose/pkg/store"
+	"github.com/stv0g/gose/pkg/tracing"
 
 	"github.com/stv0g/gose/pkg/shortener"
 )
@@ -28,14 +48,54 @@ var (
 
 const apiBase = "/api/v1"
 
+// shutdownTimeout is the time given to pending requests on termination.
+// It stays below the grace period of 30s after which Kubernetes kills a pod.
+const shutdownTimeout = 25 * time.Second
+
+type command struct {
+	run         func(args []string) error
+	description string
+}
+
+// commands are the subcommands of GoSƐ. The server is started if none is given.
+var commands = map[string]command{
+	"upload":       {runUpload, "upload a file"},
+	"download":     {runDownload, "download a file"},
+	"setup":        {runSetup, "set up the buckets and exit"},
+	"ls":           {runList, "list uploaded files"},
+	"rm":           {runRemove, "delete uploaded files"},
+	"gc":           {runGC, "abort stale uploads and delete expired files"},
+	"check-config": {runCheckConfig, "validate the configuration"},
+}
+
 func main() {
-	log.Printf("GoSƐ %s, commit %s, built at %s by %s\n", version, commit, date, builtBy)
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				exitError(err)
+			}
+
+			return
+		}
+	}
+
+	flag.Usage = func() {
+		w := flag.CommandLine.Output()
+		fmt.Fprintf(w, "Usage: gose [flags]\n       gose COMMAND [flags] [args]\n\nCommands:\n")
+		for _, name := range slices.Sorted(maps.Keys(commands)) {
+			fmt.Fprintf(w, "  %-14s %s\n", name, commands[name].description)
+		}
+		fmt.Fprintf(w, "\nFlags:\n")
+		flag.PrintDefaults()
+	}
+
+	slog.Info("Starting GoSƐ", "version", version, "commit", commit, "date", date, "built_by", builtBy)
 
 	// Generate our config based on the config supplied
 	// by the user in the flags.
 	cfgFile, showVersion, err := config.ParseFlags()
 	if err != nil {
-		log.Fatal(err)
+		fatal("Failed to parse flags", "error", err)
 	}
 
 	if showVersion {
@@ -44,51 +104,249 @@ func main() {
 
 	cfg, err := config.NewConfig(cfgFile)
 	if err != nil {
-		log.Fatal(err)
+		fatal("Failed to load configuration", "error", err)
+	}
+
+	logger, err := logging.New(&cfg.Log, os.Stderr)
+	if err != nil {
+		fatal("Failed to create logger", "error", err)
+	}
+
+	slog.SetDefault(logger)
+
+	if cfg.Log.DumpConfig {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			fatal("Failed to dump configuration", "error", err)
+		}
+	}
+
+	var shutdownTracing func(context.Context) error
+	if cfg.Tracing != nil {
+		if shutdownTracing, err = tracing.Setup(context.Background(), cfg.Tracing, version); err != nil {
+			fatal("Failed to setup tracing", "error", err)
+		}
+
+		slog.Info("Exporting traces", "endpoint", cfg.Tracing.Endpoint, "sample_ratio", *cfg.Tracing.SampleRatio)
 	}
 
-	// Run the server.
-	run(cfg)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Run the server until it receives a signal.
+	run(ctx, cfg)
+
+	// Spans of the last requests are still pending in the batcher.
+	if shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}
 }
 
 // APIMiddleware will add the db connection to the context.
-func APIMiddleware(svrs server.List, shortener *shortener.Shortener, cfg *config.Config) gin.HandlerFunc {
+func APIMiddleware(svrs server.List, shortener *shortener.Shortener, signer *session.Signer, quotas *quota.Manager, scan *scanner.Scanner, abuses *abuse.Manager, bans *ban.List, auditLog *audit.Logger, checker *health.Checker, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		c.Set("health", checker)
+		c.Set("audit", auditLog)
+		c.Set("abuse", abuses)
+		c.Set("bans", bans)
+		c.Set("scanner", scan)
 		c.Set("servers", svrs)
 		c.Set("config", cfg)
 		c.Set("shortener", shortener)
+		c.Set("sessions", signer)
+		c.Set("quota", quotas)
 		c.Next()
 	}
 }
 
-func run(cfg *config.Config) {
+// RateLimitMiddleware limits the request rate per client IP for a group of routes.
+// Requests are not limited if no limit is configured.
+func RateLimitMiddleware(cfg *config.RateLimitConfig, l *config.RateLimit, group string) gin.HandlerFunc {
+	if l == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	var limiter ratelimit.Limiter
+	if cfg.Redis != "" {
+		var err error
+		if limiter, err = ratelimit.NewRedis(cfg.Redis, l.Rate, l.Burst); err != nil {
+			fatal("Failed to create rate limiter", "error", err)
+		}
+	} else {
+		limiter = ratelimit.NewMemory(l.Rate, l.Burst)
+	}
+
+	return ratelimit.Middleware(limiter, group)
+}
+
+func run(ctx context.Context, cfg *config.Config) {
 	svrs := server.NewList(cfg.Servers)
 
-	log.Printf("Initializing S3 servers. Please wait...")
+	slog.Info("Initializing S3 servers. Please wait...")
 	if err := svrs.Setup(); err != nil {
-		log.Fatalf("Failed to setup servers: %s", err)
+		fatal("Failed to setup servers", "error", err)
 	}
-	log.Printf("Initialization of %d servers completed.", len(svrs))
+	slog.Info("Initialization of servers completed", "count", len(svrs))
 
 	var err error
 	var short *shortener.Shortener
 	if cfg.Shortener != nil {
 		if short, err = shortener.NewShortener(cfg.Shortener); err != nil {
-			log.Fatalf("Failed to create link shortener: %s", err)
+			fatal("Failed to create link shortener", "error", err)
+		}
+	}
+
+	secret := []byte(cfg.Secret)
+	if len(secret) == 0 {
+		slog.Warn("No secret configured. Using a random one which is not shared between replicas!")
+		if secret, err = session.RandomSecret(); err != nil {
+			fatal("Failed to generate secret", "error", err)
+		}
+	}
+
+	signer := session.NewSigner(secret, cfg.SessionValidity)
+
+	state := store.NewS3(svrs[cfg.State.Server], cfg.State.Prefix)
+
+	var quotas *quota.Manager
+	if cfg.Quota != nil {
+		quotas = quota.NewManager(cfg.Quota, state, cfg.SessionValidity)
+	}
+
+	abuses := abuse.NewManager(state, cfg.State.Refresh)
+
+	bans, err := ban.NewList(state, cfg.State.Refresh, cfg.Bans)
+	if err != nil {
+		fatal("Failed to create ban list", "error", err)
+	}
+
+	checker := health.NewChecker(cfg.Health.Interval, cfg.Health.Timeout)
+
+	for id, svr := range svrs {
+		checker.Add(svr.Component(), cfg.Health.IsCritical(id), svr.Check)
+	}
+
+	if short != nil {
+		checker.Add("shortener", false, short.Ping)
+	}
+
+	if n := cfg.Notification; n != nil {
+		checker.Add("notifier", false, func(context.Context) error {
+			if _, err := notifier.NewNotifier(n.Template, n.URLs...); err != nil {
+				return err
+			}
+
+			if n.Mail != nil {
+				if _, err := notifier.NewNotifier(n.Mail.Template, n.Mail.URL); err != nil {
+					return fmt.Errorf("mail: %w", err)
+				}
+			}
+
+			return nil
+		})
+	}
+
+	var auditLog *audit.Logger
+	if cfg.Audit != nil {
+		var job *health.Job
+		if cfg.Audit.Bucket != nil {
+			job = checker.Job("audit", false, 3*cfg.Audit.Bucket.FlushInterval)
+		}
+
+		if auditLog, err = audit.NewLogger(cfg.Audit, svrs, job); err != nil {
+			fatal("Failed to create audit log", "error", err)
+		}
+	}
+
+	var scan *scanner.Scanner
+	if cfg.Scanner != nil {
+		scan = scanner.NewScanner(cfg.Scanner)
+		if err := scan.Ping(); err != nil {
+			slog.Warn("Failed to reach clamd", "address", cfg.Scanner.Address, "error", err)
 		}
+
+		checker.Add("scanner", false, func(context.Context) error {
+			return scan.Ping()
+		})
+	}
+
+	checker.Start()
+
+	rl := cfg.RateLimit
+	if rl == nil {
+		rl = &config.RateLimitConfig{}
+	}
+
+	limitAPI := RateLimitMiddleware(rl, rl.API, "api")
+	limitDownload := RateLimitMiddleware(rl, rl.Download, "download")
+
+	banned := ban.Middleware(bans, handlers.Identity)
+
+	adminIdentity := func(c *gin.Context) string {
+		return c.GetString(gin.AuthUserKey)
+	}
+
+	router := gin.New()
+	router.Use(otelgin.Middleware("gose"), logging.Middleware(slog.Default()), gin.Recovery())
+
+	// Forwarding headers are ignored unless proxies are configured.
+	// Otherwise clients could spoof the IP used for rate limits, bans, quotas and ownership.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", "error", err)
 	}
 
-	router := gin.Default()
-	router.Use(APIMiddleware(svrs, short, cfg))
+	router.Use(APIMiddleware(svrs, short, signer, quotas, scan, abuses, bans, auditLog, checker, cfg))
 	router.Use(StaticMiddleware(cfg))
 
 	router.GET(apiBase+"/config", handlers.HandleConfigWith(version, commit, date))
-	router.GET(apiBase+"/healthz", handlers.HandleHealthz)
-	router.POST(apiBase+"/initiate", handlers.HandleInitiate)
-	router.POST(apiBase+"/part", handlers.HandlePart)
-	router.POST(apiBase+"/complete", handlers.HandleComplete)
-	router.GET(apiBase+"/download/:server/:etag/:filename", handlers.HandleDownload)
-	router.HEAD(apiBase+"/download/:server/:etag/:filename", handlers.HandleDownload)
+	router.GET(apiBase+"/livez", handlers.HandleLivez)
+	router.GET(apiBase+"/readyz", handlers.HandleReadyzWith(checker))
+	router.GET(apiBase+"/healthz", handlers.HandleReadyzWith(checker)) // Deprecated: use readyz
+	router.GET(apiBase+"/quota", limitAPI, handlers.HandleQuota)
+
+	// Audit middlewares come first so that rate limited and banned requests are recorded as well.
+	router.POST(apiBase+"/initiate", audit.Middleware(auditLog, audit.TypeInitiate, handlers.Identity), limitAPI, banned, handlers.HandleInitiate)
+	router.POST(apiBase+"/part", limitAPI, banned, handlers.HandlePart)
+	router.POST(apiBase+"/parts", limitAPI, banned, handlers.HandleParts)
+	router.POST(apiBase+"/complete", audit.Middleware(auditLog, audit.TypeComplete, handlers.Identity), limitAPI, banned, handlers.HandleComplete)
+	router.GET(apiBase+"/download/:server/:etag/:filename", audit.Middleware(auditLog, audit.TypeDownload, handlers.Identity), limitDownload, handlers.HandleDownload)
+	router.HEAD(apiBase+"/download/:server/:etag/:filename", limitDownload, handlers.HandleDownload)
+	router.POST(apiBase+"/download/:server/:etag/:filename", audit.Middleware(auditLog, audit.TypeDownload, handlers.Identity), limitDownload, handlers.HandleDownloadKey)
+	router.GET(apiBase+"/files/:server/:etag/checksums", limitAPI, handlers.HandleChecksums)
+	router.POST(apiBase+"/files/:server/:etag/transfer", audit.Middleware(auditLog, audit.TypeTransfer, handlers.Identity), limitAPI, banned, handlers.HandleTransfer)
+	router.GET(apiBase+"/report/:server/:etag/:filename", limitAPI, handlers.HandleReportPage)
+	router.POST(apiBase+"/report/:server/:etag/:filename", audit.Middleware(auditLog, audit.TypeReport, handlers.Identity), limitAPI, handlers.HandleReport)
+
+	if cfg.Admin != nil {
+		auth := gin.BasicAuth(cfg.Admin.Accounts)
+
+		router.GET("/admin", auth, handlers.HandleAdminPage)
+
+		// Failed authentication attempts are recorded as well.
+		admin := router.Group(apiBase+"/admin", audit.Middleware(auditLog, audit.TypeAdmin, adminIdentity), auth)
+		admin.GET("/servers/:server/objects", handlers.HandleAdminObjects)
+		admin.GET("/servers/:server/objects/:etag", handlers.HandleAdminObject)
+		admin.DELETE("/servers/:server/objects/:etag", handlers.HandleAdminDeleteObject)
+		admin.POST("/servers/:server/objects/:etag/transfer", handlers.HandleAdminTransfer)
+		admin.GET("/servers/:server/uploads", handlers.HandleAdminUploads)
+		admin.DELETE("/servers/:server/uploads/:etag/:upload_id", handlers.HandleAdminAbortUpload)
+		admin.GET("/reports", handlers.HandleAdminReports)
+		admin.DELETE("/reports/:id", handlers.HandleAdminDeleteReport)
+		admin.GET("/blocklist", handlers.HandleAdminBlocklist)
+		admin.PUT("/blocklist/:etag", handlers.HandleAdminBlock)
+		admin.DELETE("/blocklist/:etag", handlers.HandleAdminUnblock)
+		admin.GET("/bans", handlers.HandleAdminBans)
+		admin.POST("/bans", handlers.HandleAdminBan)
+		admin.DELETE("/bans", handlers.HandleAdminUnban)
+		admin.GET("/audit", handlers.HandleAdminAudit)
+		admin.GET("/config", handlers.HandleAdminConfig)
+	}
 
 	server := &http.Server{
 		Addr:           cfg.Listen,
@@ -98,9 +356,37 @@ func run(cfg *config.Config) {
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	log.Printf("Listening on: http://%s", server.Addr)
+	slog.Info("Listening", "url", "http://"+server.Addr)
 
-	server.ListenAndServe()
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Failed to serve", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	slog.Info("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shutdown server", "error", err)
+	}
+
+	// Events which have not been written to the audit bucket would be lost otherwise.
+	if auditLog != nil {
+		if err := auditLog.Flush(); err != nil {
+			slog.Error("Failed to flush audit log", "error", err)
+		}
+	}
+}
+
+// fatal logs an error and terminates the process.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
 func exitError(err error) {
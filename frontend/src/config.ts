@@ -10,6 +10,9 @@ class Expiration {
 export class Server {
     id: string = "";
     title: string = "";
+    group: string = "";
+    status: string = "";
+    encryption: string = "";
 
     part_size: number = 0;
     max_upload_size: number = 0;
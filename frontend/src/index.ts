@@ -297,10 +297,14 @@ function onConfig(config: Config) {
     let selServers = document.getElementById("servers") as HTMLSelectElement;
     let divServers = document.getElementById("config-servers");
 
+    // Preselect the first server which is not known to be down
+    let selected = config.servers.find(svr => svr.status !== "down") || config.servers[0];
+
     for (let svr of config.servers) {
         var opt = document.createElement("option");
         opt.value = svr.id;
-        opt.innerHTML = svr.title;
+        opt.innerHTML = svr.status === "down" ? `${svr.title} (unavailable)` : svr.title;
+        opt.selected = svr === selected;
         selServers.appendChild(opt);
 
         servers[svr.id] = svr;
@@ -318,7 +322,7 @@ function onConfig(config: Config) {
             }
         }
     });
-    updateExpiration(config.servers[0]);
+    updateExpiration(selected);
 
     // Update settings pane
     if (config.features.short_url) {
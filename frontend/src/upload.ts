@@ -157,18 +157,28 @@ export class Upload {
     async upload() {
         this.stage = "uploading";
 
+        // Sessions of previous uploads allow us to resume them
+        let sessionKey = `session-${this.params.server}-${this.etag}`;
+
         let respInitiate = await apiRequest("initiate", {
             server: this.params.server,
             filename: this.file.name,
             etag: this.etag,
             short_url: this.params.short_url,
-            type: this.file.type
+            type: this.file.type,
+            size: this.file.size,
+            session: localStorage.getItem(sessionKey) || undefined
         });
 
         if (respInitiate.upload_id === undefined) {
             return respInitiate.url;
         }
 
+        localStorage.setItem(sessionKey, respInitiate.session);
+
+        // The server might have been chosen by GoSƐ
+        let server = respInitiate.server || this.params.server;
+
         this.url = respInitiate.url;
 
         let existingParts: {[x: number]: Part} = {};
@@ -192,20 +202,24 @@ export class Upload {
             let chunk = this.file.slice(part.offset, part.offset + part.length);
 
             let partResp = await apiRequest("part", {
-                server: this.params.server,
+                server: server,
                 etag: respInitiate.etag,
                 upload_id: respInitiate.upload_id,
+                session: respInitiate.session,
                 checksum: buf2hex(part.etag),
                 length: part.length,
                 number: part.number
             });
 
-            let etag = await this.uploadPart(partResp.url, chunk);
+            let etag = await this.uploadPart(partResp.url, chunk, partResp.headers);
             if (!this.file) {
                 throw "Aborted";
             }
 
-            if (etag !== "\"" + buf2hex(part.etag) + "\"") {
+            // ETags of parts encrypted with SSE-KMS or SSE-C are no MD5 digests.
+            // S3 checks the Content-MD5 header instead.
+            let encrypted = ["sse-kms", "sse-c"].indexOf(this.server.encryption) >= 0;
+            if (!encrypted && etag !== "\"" + buf2hex(part.etag) + "\"") {
                 throw "Checksum mismatch";
             }
         }
@@ -213,25 +227,34 @@ export class Upload {
         this.progress.end();
 
         let respComplete = await apiRequest("complete", {
-            server: this.params.server,
+            server: server,
             etag: respInitiate.etag,
             upload_id: respInitiate.upload_id,
+            session: respInitiate.session,
             parts: this.parts.map(p => p.toJSON()),
             expiration: this.params.expiration,
             notify_mail: this.params.notify_mail,
         });
 
-        if (respComplete.etag !== this.etag) {
+        localStorage.removeItem(sessionKey);
+
+        // Uploads encrypted with SSE-C are stored under a random key.
+        if (respComplete.etag !== respInitiate.etag) {
             throw "Final checksum mismatch";
         }
 
         return respInitiate.url || respComplete.url;
     }
 
-    async uploadPart(url: string, part: Blob) {
+    async uploadPart(url: string, part: Blob, headers?: {[name: string]: string}) {
         let prom = new Promise<XMLHttpRequest>((resolve, reject) => {
             this.xhr = new XMLHttpRequest();
             this.xhr.open("PUT", url);
+
+            // Signed headers like the Content-MD5 of the part
+            for (let name in headers || {}) {
+                this.xhr.setRequestHeader(name, headers[name]);
+            }
             this.xhr.onload = () => {
                 if (this.xhr.status >= 200 && this.xhr.status < 300) {
                     resolve(this.xhr);
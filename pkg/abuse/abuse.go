@@ -0,0 +1,219 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package abuse records abuse reports and maintains a block list of ETags.
+package abuse
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stv0g/gose/pkg/store"
+)
+
+const (
+	blocklistKey  = "abuse/blocklist.json"
+	reportsPrefix = "abuse/reports/"
+)
+
+// Report is a complaint about an uploaded file.
+type Report struct {
+	ID       string    `json:"id"`
+	Server   string    `json:"server"`
+	ETag     string    `json:"etag"`
+	FileName string    `json:"filename"`
+	Reason   string    `json:"reason"`
+	Comment  string    `json:"comment,omitempty"`
+	Reporter string    `json:"reporter"`
+	Created  time.Time `json:"created"`
+}
+
+// Block describes a blocked ETag.
+type Block struct {
+	ETag      string    `json:"etag"`
+	Reason    string    `json:"reason"`
+	BlockedBy string    `json:"blocked_by"`
+	Created   time.Time `json:"created"`
+}
+
+// Manager stores abuse reports and checks ETags against the block list.
+type Manager struct {
+	store store.Store
+
+	// The block list is cached and reloaded after this interval.
+	refresh time.Duration
+
+	blocked map[string]*Block
+	loaded  time.Time
+	mu      sync.RWMutex
+
+	now func() time.Time
+}
+
+// NewManager creates a new abuse manager.
+func NewManager(st store.Store, refresh time.Duration) *Manager {
+	return &Manager{
+		store:   st,
+		refresh: refresh,
+		now:     time.Now,
+	}
+}
+
+// Report records a new abuse report.
+func (m *Manager) Report(r *Report) error {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	r.Created = m.now().UTC()
+
+	// Keys are sortable by their creation time.
+	r.ID = r.Created.Format("20060102T150405Z") + "-" + hex.EncodeToString(id)
+
+	return m.store.Put(reportsPrefix+r.ID+".json", r)
+}
+
+// Reports returns all abuse reports in the order in which they have been reported.
+// If etag is not empty, only reports for this ETag are returned.
+func (m *Manager) Reports(etag string) ([]*Report, error) {
+	keys, err := m.store.List(reportsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+
+	reports := []*Report{}
+	for _, key := range keys {
+		r := &Report{}
+		if err := m.store.Get(key, r); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		if etag == "" || r.ETag == etag {
+			reports = append(reports, r)
+		}
+	}
+
+	return reports, nil
+}
+
+// DeleteReport removes an abuse report.
+func (m *Manager) DeleteReport(id string) error {
+	if strings.ContainsAny(id, "/.") {
+		return store.ErrNotFound
+	}
+
+	return m.store.Delete(reportsPrefix + id + ".json")
+}
+
+// Block adds an ETag to the block list.
+func (m *Manager) Block(etag, reason, blockedBy string) (*Block, error) {
+	b := &Block{
+		ETag:      etag,
+		Reason:    reason,
+		BlockedBy: blockedBy,
+		Created:   m.now().UTC(),
+	}
+
+	blocked := map[string]*Block{}
+	if err := store.Update(m.store, blocklistKey, &blocked, func() error {
+		blocked[etag] = b
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	m.set(blocked)
+
+	return b, nil
+}
+
+// Unblock removes an ETag from the block list.
+func (m *Manager) Unblock(etag string) error {
+	blocked := map[string]*Block{}
+	if err := store.Update(m.store, blocklistKey, &blocked, func() error {
+		if _, ok := blocked[etag]; !ok {
+			return store.ErrNotFound
+		}
+
+		delete(blocked, etag)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	m.set(blocked)
+
+	return nil
+}
+
+// Blocklist returns the current block list.
+func (m *Manager) Blocklist() ([]*Block, error) {
+	blocked, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	m.set(blocked)
+
+	list := []*Block{}
+	for _, b := range blocked {
+		list = append(list, b)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+
+	return list, nil
+}
+
+// Blocked returns the block list entry of an ETag or nil if it is not blocked.
+// The block list is cached and changes of other replicas are picked up after the refresh interval.
+func (m *Manager) Blocked(etag string) (*Block, error) {
+	m.mu.RLock()
+	stale := m.blocked == nil || m.now().Sub(m.loaded) > m.refresh
+	m.mu.RUnlock()
+
+	if stale {
+		blocked, err := m.load()
+		if err != nil {
+			return nil, err
+		}
+
+		m.set(blocked)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.blocked[etag], nil
+}
+
+func (m *Manager) load() (map[string]*Block, error) {
+	blocked := map[string]*Block{}
+	if err := m.store.Get(blocklistKey, &blocked); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	return blocked, nil
+}
+
+func (m *Manager) set(blocked map[string]*Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.blocked = blocked
+	m.loaded = m.now()
+}
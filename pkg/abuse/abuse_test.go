@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package abuse_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stv0g/gose/pkg/abuse"
+	"github.com/stv0g/gose/pkg/store"
+)
+
+const etag = "d41d8cd98f00b204e9800998ecf8427e-1"
+
+func TestReports(t *testing.T) {
+	m := abuse.NewManager(store.NewMemory(), time.Minute)
+
+	for _, e := range []string{etag, "other-1", etag} {
+		if err := m.Report(&abuse.Report{
+			Server: "svr",
+			ETag:   e,
+			Reason: "copyright",
+		}); err != nil {
+			t.Fatalf("Failed to report: %s", err)
+		}
+	}
+
+	if rs, err := m.Reports(""); err != nil || len(rs) != 3 {
+		t.Fatalf("Unexpected reports: %v, %v", rs, err)
+	}
+
+	rs, err := m.Reports(etag)
+	if err != nil || len(rs) != 2 {
+		t.Fatalf("Unexpected reports: %v, %v", rs, err)
+	}
+
+	if err := m.DeleteReport(rs[0].ID); err != nil {
+		t.Fatalf("Failed to delete report: %s", err)
+	}
+
+	if rs, err := m.Reports(etag); err != nil || len(rs) != 1 {
+		t.Fatalf("Unexpected reports: %v, %v", rs, err)
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	st := store.NewMemory()
+
+	// Two replicas with different refresh intervals.
+	cached := abuse.NewManager(st, time.Hour)
+	fresh := abuse.NewManager(st, 0)
+
+	if b, err := cached.Blocked(etag); err != nil || b != nil {
+		t.Fatalf("Unexpected block: %v, %v", b, err)
+	}
+
+	if _, err := fresh.Block(etag, "copyright", "admin"); err != nil {
+		t.Fatalf("Failed to block: %s", err)
+	}
+
+	if b, err := fresh.Blocked(etag); err != nil || b == nil || b.BlockedBy != "admin" {
+		t.Fatalf("Expected block, got: %v, %v", b, err)
+	}
+
+	// The other replica has not yet reloaded the block list.
+	if b, err := cached.Blocked(etag); err != nil || b != nil {
+		t.Fatalf("Unexpected block: %v, %v", b, err)
+	}
+
+	if l, err := cached.Blocklist(); err != nil || len(l) != 1 {
+		t.Fatalf("Unexpected block list: %v, %v", l, err)
+	}
+
+	if err := cached.Unblock(etag); err != nil {
+		t.Fatalf("Failed to unblock: %s", err)
+	}
+
+	if err := cached.Unblock(etag); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected not found, got: %v", err)
+	}
+
+	if b, err := fresh.Blocked(etag); err != nil || b != nil {
+		t.Fatalf("Unexpected block: %v, %v", b, err)
+	}
+}
@@ -0,0 +1,217 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package audit records uploads, downloads and admin actions in an append-only log.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/health"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+// Types of events.
+const (
+	TypeInitiate = "upload.initiate"
+	TypeComplete = "upload.complete"
+	TypeDownload = "download"
+	TypeReport   = "report"
+	TypeTransfer = "transfer"
+	TypeAdmin    = "admin"
+)
+
+// Results of events.
+const (
+	ResultSuccess = "success"
+	ResultDenied  = "denied"
+	ResultFailure = "failure"
+)
+
+// ErrNotQueryable is returned if events are only written to the standard output.
+var ErrNotQueryable = errors.New("audit log is not queryable")
+
+// Event is a single entry of the audit log.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Request   string    `json:"request"`
+	Server    string    `json:"server,omitempty"`
+	ETag      string    `json:"etag,omitempty"`
+	FileName  string    `json:"filename,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	ClientIP  string    `json:"client_ip"`
+	UserAgent string    `json:"user_agent"`
+	Identity  string    `json:"identity"`
+	Status    int       `json:"status"`
+	Result    string    `json:"result"`
+}
+
+// Filter selects events of the audit log.
+// Empty fields match all events.
+type Filter struct {
+	ETag string
+	Type string
+	From time.Time
+	To   time.Time
+
+	// Limit is the maximum number of returned events.
+	Limit int
+}
+
+// Logger writes events to the configured destinations.
+type Logger struct {
+	writers []io.Writer
+	file    string
+	bucket  *bucket
+
+	mu sync.Mutex
+}
+
+// NewLogger creates a new audit logger.
+// The periodic flushes to the bucket are recorded as runs of job, which might be nil.
+func NewLogger(cfg *config.AuditConfig, svrs server.List, job *health.Job) (*Logger, error) {
+	l := &Logger{}
+
+	if cfg.Stdout {
+		l.writers = append(l.writers, os.Stdout)
+	}
+
+	if cfg.File != "" {
+		f, err := os.OpenFile(cfg.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+
+		l.writers = append(l.writers, f)
+		l.file = cfg.File
+	}
+
+	if cfg.Bucket != nil {
+		svr, ok := svrs[cfg.Bucket.Server]
+		if !ok {
+			return nil, fmt.Errorf("unknown audit server: %s", cfg.Bucket.Server)
+		}
+
+		l.bucket = newBucket(svr, cfg.Bucket.Prefix, cfg.Bucket.FlushInterval, job)
+	}
+
+	return l, nil
+}
+
+// Log appends an event to the audit log.
+// Failures are logged but do not interrupt the request.
+func (l *Logger) Log(e *Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	buf, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("Failed to encode audit event", "error", err)
+		return
+	}
+
+	buf = append(buf, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, w := range l.writers {
+		if _, err := w.Write(buf); err != nil {
+			slog.Error("Failed to write audit event", "error", err)
+		}
+	}
+
+	if l.bucket != nil {
+		l.bucket.add(buf)
+	}
+}
+
+// Flush writes pending events to the bucket.
+func (l *Logger) Flush() error {
+	if l.bucket == nil {
+		return nil
+	}
+
+	return l.bucket.flush()
+}
+
+// Query returns the events matching the filter in chronological order.
+// Events are read from the bucket if configured, or from the file otherwise.
+func (l *Logger) Query(f *Filter) ([]*Event, error) {
+	events := []*Event{}
+	collect := func(r io.Reader) (bool, error) {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64<<10), 1<<20)
+
+		for sc.Scan() {
+			e := &Event{}
+			if err := json.Unmarshal(sc.Bytes(), e); err != nil {
+				continue
+			}
+
+			if f.match(e) {
+				events = append(events, e)
+
+				if f.Limit > 0 && len(events) >= f.Limit {
+					return false, nil
+				}
+			}
+		}
+
+		return true, sc.Err()
+	}
+
+	switch {
+	case l.bucket != nil:
+		if err := l.bucket.read(f.From, f.To, collect); err != nil {
+			return nil, err
+		}
+
+	case l.file != "":
+		fh, err := os.Open(l.file)
+		if err != nil {
+			return nil, err
+		}
+		defer fh.Close()
+
+		if _, err := collect(fh); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, ErrNotQueryable
+	}
+
+	return events, nil
+}
+
+func (f *Filter) match(e *Event) bool {
+	if f.ETag != "" && e.ETag != f.ETag {
+		return false
+	}
+
+	if f.Type != "" && e.Type != f.Type {
+		return false
+	}
+
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && e.Time.After(f.To) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package audit_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/audit"
+	"github.com/stv0g/gose/pkg/config"
+)
+
+func TestQueryFile(t *testing.T) {
+	l, err := audit.NewLogger(&config.AuditConfig{
+		File: filepath.Join(t.TempDir(), "audit.log"),
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %s", err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, etag := range []string{"a-1", "b-1", "a-1", "c-1"} {
+		l.Log(&audit.Event{
+			Time: start.Add(time.Duration(i) * time.Hour),
+			Type: audit.TypeDownload,
+			ETag: etag,
+		})
+	}
+
+	if events, err := l.Query(&audit.Filter{ETag: "a-1"}); err != nil || len(events) != 2 {
+		t.Fatalf("Unexpected events: %v, %v", events, err)
+	}
+
+	events, err := l.Query(&audit.Filter{
+		From: start.Add(time.Hour),
+		To:   start.Add(2 * time.Hour),
+	})
+	if err != nil || len(events) != 2 || events[0].ETag != "b-1" {
+		t.Fatalf("Unexpected events: %v, %v", events, err)
+	}
+
+	if events, err := l.Query(&audit.Filter{Limit: 3}); err != nil || len(events) != 3 {
+		t.Fatalf("Unexpected events: %v, %v", events, err)
+	}
+}
+
+func TestNotQueryable(t *testing.T) {
+	l, err := audit.NewLogger(&config.AuditConfig{
+		Stdout: true,
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %s", err)
+	}
+
+	if _, err := l.Query(&audit.Filter{}); !errors.Is(err, audit.ErrNotQueryable) {
+		t.Fatalf("Expected error, got: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l, err := audit.NewLogger(&config.AuditConfig{
+		File: filepath.Join(t.TempDir(), "audit.log"),
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %s", err)
+	}
+
+	identity := func(c *gin.Context) string {
+		return "alice"
+	}
+
+	r := gin.New()
+	r.GET("/download/:server/:etag/:filename", audit.Middleware(l, audit.TypeDownload, identity), func(c *gin.Context) {
+		audit.FromContext(c).Size = 1234
+		c.Status(http.StatusForbidden)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/download/svr/a-1/test.txt", nil)
+	req.Header.Set("User-Agent", "test")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	events, err := l.Query(&audit.Filter{})
+	if err != nil || len(events) != 1 {
+		t.Fatalf("Unexpected events: %v, %v", events, err)
+	}
+
+	e := events[0]
+	if e.Server != "svr" || e.ETag != "a-1" || e.FileName != "test.txt" || e.Size != 1234 ||
+		e.Identity != "alice" || e.UserAgent != "test" || e.Result != audit.ResultDenied {
+		t.Fatalf("Unexpected event: %+v", e)
+	}
+}
@@ -0,0 +1,216 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/health"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+// keyLayout makes the object keys sortable by the time of their first event.
+const keyLayout = "2006/01/02/150405.000000000"
+
+// maxPending limits the events kept in memory while the bucket is unavailable.
+const maxPending = 64 << 20
+
+// bucket collects events and periodically writes them as a new object into an S3 bucket.
+// Objects are never modified, so multiple replicas can share the same prefix.
+type bucket struct {
+	server     server.Server
+	prefix     string
+	interval   time.Duration
+	maxPending int
+
+	pending []byte
+	first   time.Time
+
+	// flushing are the events which are currently uploaded.
+	flushing []byte
+
+	mu sync.Mutex
+
+	// flushMu serializes flushes to keep the order of events.
+	flushMu sync.Mutex
+}
+
+func newBucket(svr server.Server, prefix string, interval time.Duration, job *health.Job) *bucket {
+	b := &bucket{
+		server:     svr,
+		prefix:     prefix,
+		interval:   interval,
+		maxPending: maxPending,
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			err := b.flush()
+			if err != nil {
+				slog.Error("Failed to flush audit log", "error", err)
+			}
+
+			job.Done(err)
+		}
+	}()
+
+	return b
+}
+
+func (b *bucket) add(line []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pending) == 0 {
+		b.first = time.Now().UTC()
+	}
+
+	b.pending = append(b.pending, line...)
+	b.truncate()
+}
+
+// flush writes the pending events.
+// They are kept for the next attempt if the upload fails.
+// Events are still added during the upload.
+func (b *bucket) flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	events, first := b.pending, b.first
+	b.pending, b.flushing = nil, events
+	b.mu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	err := b.put(events, first)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushing = nil
+
+	if err != nil {
+		if len(b.pending) > 0 {
+			events = append(events, b.pending...)
+		}
+
+		b.pending, b.first = events, first
+		b.truncate()
+	}
+
+	return err
+}
+
+func (b *bucket) put(events []byte, first time.Time) error {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	_, err := b.server.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(b.server.Config.Bucket),
+		Key:         aws.String(b.prefix + first.Format(keyLayout) + "-" + hex.EncodeToString(id) + ".jsonl"),
+		Body:        bytes.NewReader(events),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+
+	return err
+}
+
+// truncate drops the oldest pending events beyond the limit.
+// It must be called with the lock held.
+func (b *bucket) truncate() {
+	over := len(b.pending) - b.maxPending
+	if over <= 0 {
+		return
+	}
+
+	// Only whole events are dropped.
+	cut := len(b.pending)
+	if i := bytes.IndexByte(b.pending[over-1:], '\n'); i >= 0 {
+		cut = over + i
+	}
+
+	dropped := bytes.Count(b.pending[:cut], []byte("\n"))
+	b.pending = b.pending[cut:]
+
+	slog.Warn("Dropped audit events as the bucket is unavailable", "events", dropped)
+}
+
+// read passes all objects which might contain events between from and to to fn,
+// followed by the events which have not been flushed yet.
+func (b *bucket) read(from, to time.Time, fn func(io.Reader) (bool, error)) error {
+	in := &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.server.Config.Bucket),
+		Prefix: aws.String(b.prefix),
+	}
+
+	// An object contains the events of up to one flush interval after its key.
+	// Flushes might be delayed by failed uploads, so we add some margin.
+	if !from.IsZero() {
+		in.StartAfter = aws.String(b.prefix + from.UTC().Add(-2*b.interval).Format(keyLayout))
+	}
+
+	var end string
+	if !to.IsZero() {
+		end = b.prefix + to.UTC().Format(keyLayout)
+	}
+
+	var keys []string
+	if err := b.server.ListObjectsV2Pages(in, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			key := aws.StringValue(obj.Key)
+			if end != "" && key > end {
+				return false
+			}
+
+			if strings.HasSuffix(key, ".jsonl") {
+				keys = append(keys, key)
+			}
+		}
+
+		return true
+	}); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		obj, err := b.server.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(b.server.Config.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+
+		cont, err := fn(obj.Body)
+		obj.Body.Close()
+
+		if err != nil {
+			return err
+		} else if !cont {
+			return nil
+		}
+	}
+
+	b.mu.Lock()
+	pending := append(bytes.Clone(b.flushing), b.pending...)
+	b.mu.Unlock()
+
+	_, err := fn(bytes.NewReader(pending))
+
+	return err
+}
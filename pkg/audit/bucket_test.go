@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package audit_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stv0g/gose/pkg/audit"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+func TestBucketFlush(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan int)
+	uploads := make(chan string, 1)
+
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+
+			started <- struct{}{}
+			status := <-release
+			if status == http.StatusOK {
+				uploads <- string(body)
+			}
+
+			w.WriteHeader(status)
+
+		default:
+			io.WriteString(w, `<ListBucketResult></ListBucketResult>`)
+		}
+	}))
+	defer s3.Close()
+
+	svrs := server.NewList([]config.S3Server{
+		{
+			S3ServerConfig: config.S3ServerConfig{
+				ID:       "s1",
+				PartSize: config.MinPartSize,
+			},
+			Endpoint:  strings.TrimPrefix(s3.URL, "http://"),
+			Bucket:    "bucket",
+			Region:    "us-east-1",
+			PathStyle: true,
+			NoSSL:     true,
+			AccessKey: "access",
+			SecretKey: "secret",
+		},
+	})
+
+	l, err := audit.NewLogger(&config.AuditConfig{
+		Bucket: &config.AuditBucketConfig{
+			Server:        "s1",
+			FlushInterval: time.Hour,
+		},
+	}, svrs, nil)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %s", err)
+	}
+
+	event := func(etag string) *audit.Event {
+		return &audit.Event{
+			Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Type: audit.TypeDownload,
+			ETag: etag,
+		}
+	}
+
+	line, _ := json.Marshal(event("a-1"))
+
+	// Only two events fit.
+	l.SetMaxPending(2 * (len(line) + 1))
+
+	l.Log(event("a-1"))
+	l.Log(event("a-2"))
+
+	flushed := make(chan error)
+	go func() {
+		flushed <- l.Flush()
+	}()
+
+	<-started
+
+	// Events are added and can be queried while the upload is in progress.
+	logged := make(chan struct{})
+	go func() {
+		l.Log(event("a-3"))
+		close(logged)
+	}()
+
+	select {
+	case <-logged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Logging is blocked by the upload")
+	}
+
+	if events, err := l.Query(&audit.Filter{}); err != nil || len(events) != 3 {
+		t.Fatalf("Unexpected events: %v, %v", events, err)
+	}
+
+	release <- http.StatusForbidden
+	if err := <-flushed; err == nil {
+		t.Fatal("Expected error")
+	}
+
+	// The oldest event is dropped to stay within the limit.
+	go func() {
+		flushed <- l.Flush()
+	}()
+
+	<-started
+	release <- http.StatusOK
+
+	if err := <-flushed; err != nil {
+		t.Fatalf("Failed to flush: %s", err)
+	}
+
+	if body := <-uploads; strings.Contains(body, "a-1") || !strings.Contains(body, "a-2") || !strings.Contains(body, "a-3") {
+		t.Fatalf("Unexpected upload: %s", body)
+	}
+}
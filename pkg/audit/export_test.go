@@ -0,0 +1,9 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+// SetMaxPending limits the events kept while the bucket is unavailable in tests.
+func (l *Logger) SetMaxPending(n int) {
+	l.bucket.maxPending = n
+}
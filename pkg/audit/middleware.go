@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contextKey = "audit.event"
+
+// Middleware records an event of the given type for each request.
+// Handlers can add details about the file via FromContext.
+// Requests are not recorded if the logger is nil.
+func Middleware(l *Logger, typ string, identity func(*gin.Context) string) gin.HandlerFunc {
+	if l == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		e := &Event{
+			Type:     typ,
+			Request:  c.Request.Method + " " + c.Request.URL.RequestURI(),
+			Server:   c.Param("server"),
+			ETag:     c.Param("etag"),
+			FileName: c.Param("filename"),
+		}
+
+		c.Set(contextKey, e)
+		c.Next()
+
+		e.ClientIP = c.ClientIP()
+		e.UserAgent = c.Request.UserAgent()
+		e.Identity = identity(c)
+		e.Status = c.Writer.Status()
+
+		switch {
+		case e.Status < http.StatusBadRequest:
+			e.Result = ResultSuccess
+		case e.Status == http.StatusUnauthorized ||
+			e.Status == http.StatusForbidden ||
+			e.Status == http.StatusTooManyRequests ||
+			e.Status == http.StatusUnavailableForLegalReasons:
+			e.Result = ResultDenied
+		default:
+			e.Result = ResultFailure
+		}
+
+		l.Log(e)
+	}
+}
+
+// FromContext returns the event of the current request or nil if it is not recorded.
+func FromContext(c *gin.Context) *Event {
+	if e, ok := c.Get(contextKey); ok {
+		return e.(*Event)
+	}
+
+	return nil
+}
@@ -0,0 +1,306 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ban prevents banned uploaders from uploading files.
+package ban
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/store"
+)
+
+const (
+	storeKey = "bans.json"
+
+	// ConfiguredBy is the originator of bans from the configuration file.
+	ConfiguredBy = "config"
+)
+
+var (
+	// ErrInvalidSubject is returned for empty ban subjects.
+	ErrInvalidSubject = errors.New("invalid subject")
+
+	// ErrConfigured is returned when removing a ban from the configuration file.
+	ErrConfigured = errors.New("ban is part of the configuration")
+)
+
+// Ban describes a banned IP address, network or uploader identity.
+type Ban struct {
+	// Subject is either an IP address, a CIDR or an uploader identity.
+	Subject  string    `json:"subject"`
+	Reason   string    `json:"reason"`
+	BannedBy string    `json:"banned_by"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires,omitempty"`
+
+	network *net.IPNet
+}
+
+// List is a list of bans which is shared between replicas via a store.
+type List struct {
+	store store.Store
+
+	// The persisted bans are cached and reloaded after this interval.
+	refresh time.Duration
+
+	configured map[string]*Ban
+	persisted  map[string]*Ban
+	loaded     time.Time
+	mu         sync.RWMutex
+
+	now func() time.Time
+}
+
+// NewList creates a new ban list including the bans of the configuration file.
+func NewList(st store.Store, refresh time.Duration, cfg []config.BanConfig) (*List, error) {
+	l := &List{
+		store:      st,
+		refresh:    refresh,
+		configured: map[string]*Ban{},
+		now:        time.Now,
+	}
+
+	for _, c := range cfg {
+		b, err := newBan(c.Subject, c.Reason, ConfiguredBy, time.Time{}, c.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		l.configured[b.Subject] = b
+	}
+
+	return l, nil
+}
+
+// Add bans a subject.
+// An existing ban of the same subject is replaced.
+func (l *List) Add(subject, reason, bannedBy string, expires time.Time) (*Ban, error) {
+	b, err := newBan(subject, reason, bannedBy, l.now().UTC(), expires)
+	if err != nil {
+		return nil, err
+	}
+
+	bans := map[string]*Ban{}
+	if err := store.Update(l.store, storeKey, &bans, func() error {
+		l.prune(bans)
+		bans[b.Subject] = b
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	l.set(bans)
+
+	return b, nil
+}
+
+// Remove lifts the ban of a subject.
+func (l *List) Remove(subject string) error {
+	subject = normalize(subject)
+
+	if _, ok := l.configured[subject]; ok {
+		return ErrConfigured
+	}
+
+	bans := map[string]*Ban{}
+	if err := store.Update(l.store, storeKey, &bans, func() error {
+		l.prune(bans)
+
+		if _, ok := bans[subject]; !ok {
+			return store.ErrNotFound
+		}
+
+		delete(bans, subject)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	l.set(bans)
+
+	return nil
+}
+
+// Bans returns all active bans.
+func (l *List) Bans() ([]*Ban, error) {
+	bans, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	l.set(bans)
+
+	list := []*Ban{}
+	for _, b := range l.active() {
+		list = append(list, b)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Subject < list[j].Subject
+	})
+
+	return list, nil
+}
+
+// Banned returns the first active ban matching either the IP address or the identity of a client.
+// It returns nil if the client is not banned.
+func (l *List) Banned(ip, identity string) (*Ban, error) {
+	l.mu.RLock()
+	stale := l.persisted == nil || l.now().Sub(l.loaded) > l.refresh
+	l.mu.RUnlock()
+
+	if stale {
+		bans, err := l.load()
+		if err != nil {
+			return nil, err
+		}
+
+		l.set(bans)
+	}
+
+	addr := net.ParseIP(ip)
+
+	for _, b := range l.active() {
+		if b.network != nil {
+			if addr != nil && b.network.Contains(addr) {
+				return b, nil
+			}
+		} else if b.Subject == identity {
+			return b, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Middleware rejects requests of banned clients.
+func Middleware(l *List, identity func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b, err := l.Banned(c.ClientIP(), identity(c))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check ban list"})
+			return
+		} else if b != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "banned: " + b.Reason})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// active returns all configured and persisted bans which have not expired.
+func (l *List) active() []*Ban {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	now := l.now()
+	bans := []*Ban{}
+
+	for _, m := range []map[string]*Ban{l.configured, l.persisted} {
+		for _, b := range m {
+			if b.Expires.IsZero() || now.Before(b.Expires) {
+				bans = append(bans, b)
+			}
+		}
+	}
+
+	return bans
+}
+
+// prune removes expired bans.
+func (l *List) prune(bans map[string]*Ban) {
+	now := l.now()
+
+	for s, b := range bans {
+		if !b.Expires.IsZero() && now.After(b.Expires) {
+			delete(bans, s)
+		}
+	}
+}
+
+func (l *List) load() (map[string]*Ban, error) {
+	bans := map[string]*Ban{}
+	if err := l.store.Get(storeKey, &bans); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	return bans, nil
+}
+
+func (l *List) set(bans map[string]*Ban) {
+	for _, b := range bans {
+		b.network = network(b.Subject)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.persisted = bans
+	l.loaded = l.now()
+}
+
+func newBan(subject, reason, bannedBy string, created, expires time.Time) (*Ban, error) {
+	subject = normalize(subject)
+	if subject == "" {
+		return nil, ErrInvalidSubject
+	}
+
+	return &Ban{
+		Subject:  subject,
+		Reason:   reason,
+		BannedBy: bannedBy,
+		Created:  created,
+		Expires:  expires,
+		network:  network(subject),
+	}, nil
+}
+
+// normalize returns the canonical form of IP addresses and CIDRs.
+// Other subjects are identities and returned unchanged.
+func normalize(subject string) string {
+	subject = strings.TrimSpace(subject)
+
+	if n := network(subject); n != nil {
+		if ones, bits := n.Mask.Size(); ones == bits {
+			return n.IP.String()
+		}
+
+		return n.String()
+	}
+
+	return subject
+}
+
+// network parses an IP address or CIDR.
+// Single addresses are returned as a network containing only this address.
+func network(subject string) *net.IPNet {
+	if _, n, err := net.ParseCIDR(subject); err == nil {
+		return n
+	}
+
+	if ip := net.ParseIP(subject); ip != nil {
+		bits := 8 * net.IPv4len
+		if ip.To4() == nil {
+			bits = 8 * net.IPv6len
+		} else {
+			ip = ip.To4()
+		}
+
+		return &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(bits, bits),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ban_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stv0g/gose/pkg/ban"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/store"
+)
+
+func TestBanList(t *testing.T) {
+	st := store.NewMemory()
+
+	l, err := ban.NewList(st, 0, []config.BanConfig{
+		{Subject: "10.1.2.3/16", Reason: "spam"},
+		{Subject: "192.0.2.1", Reason: "expired", Expires: time.Now().Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create ban list: %s", err)
+	}
+
+	// A second replica sharing the same store.
+	other, _ := ban.NewList(st, 0, nil)
+
+	for _, c := range []struct {
+		ip, identity string
+		banned       bool
+	}{
+		{"10.1.200.1", "", true},
+		{"10.2.0.1", "", false},
+		{"192.0.2.1", "", false},
+		{"2001:db8::1", "", false},
+	} {
+		if b, err := l.Banned(c.ip, c.identity); err != nil || (b != nil) != c.banned {
+			t.Errorf("Unexpected ban for %s: %v, %v", c.ip, b, err)
+		}
+	}
+
+	if _, err := l.Add("2001:db8::/32", "abuse", "admin", time.Time{}); err != nil {
+		t.Fatalf("Failed to add ban: %s", err)
+	}
+
+	if _, err := l.Add("mallory", "abuse", "admin", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to add ban: %s", err)
+	}
+
+	if b, err := other.Banned("2001:db8::1", ""); err != nil || b == nil || b.BannedBy != "admin" {
+		t.Fatalf("Expected ban, got: %v, %v", b, err)
+	}
+
+	if b, err := other.Banned("198.51.100.1", "mallory"); err != nil || b == nil {
+		t.Fatalf("Expected ban, got: %v, %v", b, err)
+	}
+
+	// Configured bans are not persisted and thus missing in the other replica.
+	if bans, err := other.Bans(); err != nil || len(bans) != 2 {
+		t.Fatalf("Unexpected bans: %v, %v", bans, err)
+	}
+
+	if bans, err := l.Bans(); err != nil || len(bans) != 3 {
+		t.Fatalf("Unexpected bans: %v, %v", bans, err)
+	}
+
+	if err := l.Remove("10.1.0.0/16"); !errors.Is(err, ban.ErrConfigured) {
+		t.Fatalf("Expected configured ban error, got: %v", err)
+	}
+
+	if err := other.Remove("mallory"); err != nil {
+		t.Fatalf("Failed to remove ban: %s", err)
+	}
+
+	if b, err := l.Banned("198.51.100.1", "mallory"); err != nil || b != nil {
+		t.Fatalf("Unexpected ban: %v, %v", b, err)
+	}
+
+	if _, err := l.Add(" ", "", "admin", time.Time{}); !errors.Is(err, ban.ErrInvalidSubject) {
+		t.Fatalf("Expected invalid subject error, got: %v", err)
+	}
+}
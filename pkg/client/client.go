@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package client implements a client for the API of GoSƐ.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const apiBase = "api/v1"
+
+// DefaultConcurrency is the default number of parts which are uploaded in parallel.
+const DefaultConcurrency = 4
+
+// ErrNoServer is returned if no suitable server could be found.
+var ErrNoServer = errors.New("no suitable server")
+
+// Expiration is an expiration class of a server.
+type Expiration struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Days  int64  `json:"days"`
+}
+
+// Server describes a server as returned by the configuration endpoint.
+type Server struct {
+	ID            string       `json:"id"`
+	Title         string       `json:"title"`
+	Group         string       `json:"group"`
+	Status        string       `json:"status"`
+	Encryption    string       `json:"encryption"`
+	PartSize      int64        `json:"part_size"`
+	MaxUploadSize int64        `json:"max_upload_size"`
+	Expiration    []Expiration `json:"expiration"`
+}
+
+// Features are the optional features enabled by the server.
+type Features struct {
+	ShortURL      bool `json:"short_url"`
+	NotifyMail    bool `json:"notify_mail"`
+	NotifyBrowser bool `json:"notify_browser"`
+}
+
+// Config is the runtime configuration of GoSƐ.
+type Config struct {
+	Servers  []Server `json:"servers"`
+	Features Features `json:"features"`
+}
+
+// Server returns the server with the given ID or nil.
+func (c *Config) Server(id string) *Server {
+	for i := range c.Servers {
+		if c.Servers[i].ID == id {
+			return &c.Servers[i]
+		}
+	}
+
+	return nil
+}
+
+// SessionStore keeps the session tokens of uploads for resuming them later.
+type SessionStore interface {
+	Get(key string) string
+	Set(key, token string)
+	Delete(key string)
+}
+
+// Client is a client for GoSƐ.
+type Client struct {
+	// HTTP is used for all requests to GoSƐ and S3.
+	HTTP *http.Client
+
+	// Concurrency is the number of parts which are uploaded in parallel.
+	Concurrency int
+
+	// Sessions stores the sessions of uploads. Uploads are not resumed if nil.
+	Sessions SessionStore
+
+	baseURL *url.URL
+}
+
+// Error is an error returned by the API of GoSƐ.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// New creates a new client for the GoSƐ instance at baseURL.
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid URL: %s", baseURL)
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	return &Client{
+		HTTP:        http.DefaultClient,
+		Concurrency: DefaultConcurrency,
+		baseURL:     u,
+	}, nil
+}
+
+// Config fetches the runtime configuration.
+func (c *Client) Config(ctx context.Context) (*Config, error) {
+	cfg := &Config{}
+	if err := c.request(ctx, http.MethodGet, "config", nil, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Client) endpoint(path string) string {
+	return c.baseURL.JoinPath(apiBase, path).String()
+}
+
+// request sends a request to an API endpoint and decodes the JSON response into resp.
+func (c *Client) request(ctx context.Context, method, path string, req, resp any) error {
+	return c.requestURL(ctx, method, c.endpoint(path), req, resp)
+}
+
+func (c *Client) requestURL(ctx context.Context, method, u string, req, resp any) error {
+	var body bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			return err
+		}
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, u, &body)
+	if err != nil {
+		return err
+	}
+
+	if req != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	r.Header.Set("Accept", "application/json")
+
+	res, err := c.HTTP.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return newError(res)
+	}
+
+	if resp == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+// newError returns the error of an API response.
+func newError(res *http.Response) error {
+	var e struct {
+		Error string `json:"error"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error == "" {
+		e.Error = http.StatusText(res.StatusCode)
+	}
+
+	return &Error{
+		StatusCode: res.StatusCode,
+		Message:    e.Error,
+	}
+}
@@ -0,0 +1,200 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/client"
+	"github.com/stv0g/gose/pkg/e2e"
+)
+
+const partSize = 64
+
+// fakeGose implements the parts of the API of GoSƐ and S3 used by the client.
+type fakeGose struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	parts      map[int]string
+	objects    map[string]string
+	encryption map[string]*e2e.Params
+}
+
+func newFakeGose() *fakeGose {
+	f := &fakeGose{
+		parts:      map[int]string{},
+		objects:    map[string]string{},
+		encryption: map[string]*e2e.Params{},
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/config", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"servers": []map[string]any{
+				{"id": "s1", "group": "default", "status": "up", "part_size": partSize},
+			},
+		})
+	})
+
+	mux.HandleFunc("POST /api/v1/initiate", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ETag       string      `json:"etag"`
+			FileName   string      `json:"filename"`
+			Encryption *e2e.Params `json:"encryption"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		f.mu.Lock()
+		f.encryption[req.ETag] = req.Encryption
+		f.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"server":    "s1",
+			"etag":      req.ETag,
+			"url":       f.URL + "/api/v1/download/s1/" + req.ETag + "/" + req.FileName,
+			"upload_id": "upload",
+			"session":   "session",
+			"parts":     []any{},
+		})
+	})
+
+	mux.HandleFunc("POST /api/v1/part", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Number int `json:"number"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"url": f.URL + "/s3/parts/" + strconv.Itoa(req.Number),
+		})
+	})
+
+	mux.HandleFunc("PUT /s3/parts/{number}", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.PathValue("number"))
+		data, _ := io.ReadAll(r.Body)
+
+		f.mu.Lock()
+		f.parts[n] = string(data)
+		f.mu.Unlock()
+
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", "\""+hex.EncodeToString(sum[:])+"\"")
+	})
+
+	mux.HandleFunc("POST /api/v1/complete", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ETag  string        `json:"etag"`
+			Parts []client.Part `json:"parts"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		f.mu.Lock()
+		var obj strings.Builder
+		for _, p := range req.Parts {
+			obj.WriteString(f.parts[int(p.Number)])
+		}
+		f.objects[req.ETag] = obj.String()
+		f.parts = map[int]string{}
+		f.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"etag": req.ETag,
+		})
+	})
+
+	mux.HandleFunc("GET /api/v1/download/s1/{etag}/{filename}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/s3/objects/"+r.PathValue("etag"), http.StatusTemporaryRedirect)
+	})
+
+	mux.HandleFunc("GET /s3/objects/{etag}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if p := f.encryption[r.PathValue("etag")]; p != nil {
+			for k, v := range p.Metadata() {
+				w.Header().Set("X-Amz-Meta-"+k, v)
+			}
+		}
+
+		io.WriteString(w, f.objects[r.PathValue("etag")])
+	})
+
+	f.Server = httptest.NewServer(mux)
+
+	return f
+}
+
+func TestUploadDownload(t *testing.T) {
+	f := newFakeGose()
+	defer f.Close()
+
+	c, err := client.New(f.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err)
+	}
+
+	for _, encrypt := range []bool{false, true} {
+		data := make([]byte, 3*partSize+10)
+		rand.Read(data)
+
+		var last client.Progress
+		res, err := c.Upload(context.Background(), bytes.NewReader(data), int64(len(data)), &client.UploadOptions{
+			FileName: "test.bin",
+			Encrypt:  encrypt,
+			Progress: func(p client.Progress) {
+				last = p
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to upload (encrypt=%t): %s", encrypt, err)
+		}
+
+		if last.Transferred != last.Total {
+			t.Fatalf("Incomplete progress: %d of %d", last.Transferred, last.Total)
+		}
+
+		if hasKey := strings.Contains(res.URL, "#key="); hasKey != encrypt {
+			t.Fatalf("Unexpected URL: %s", res.URL)
+		}
+
+		if stored := f.objects[res.ETag]; encrypt == (stored == string(data)) {
+			t.Fatalf("Unexpected stored object (encrypt=%t)", encrypt)
+		}
+
+		var buf bytes.Buffer
+		name, err := c.Download(context.Background(), res.URL, &buf, nil)
+		if err != nil {
+			t.Fatalf("Failed to download (encrypt=%t): %s", encrypt, err)
+		}
+
+		if name != "test.bin" {
+			t.Fatalf("Unexpected file name: %s", name)
+		}
+
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("Downloaded data mismatch (encrypt=%t)", encrypt)
+		}
+
+		if encrypt {
+			link := strings.Split(res.URL, "#")[0]
+			if _, err := c.Download(context.Background(), link, io.Discard, nil); err == nil {
+				t.Fatal("Expected error for link without key")
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/stv0g/gose/pkg/e2e"
+)
+
+// maxRedirects is the maximum number of redirects of link shorteners which are followed.
+const maxRedirects = 10
+
+var (
+	// ErrInvalidLink is returned for links which do not point to a download.
+	ErrInvalidLink = errors.New("invalid download link")
+
+	// ErrMissingKey is returned if the link of an encrypted file does not include its key.
+	ErrMissingKey = errors.New("link does not include the key of the encrypted file")
+)
+
+type downloadKeyRequest struct {
+	Key string `json:"key"`
+}
+
+type downloadKeyResponse struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+// Download downloads the file of a link and writes its content to w.
+// Shortened links are resolved first.
+// End-to-end encrypted files are decrypted with the key from the fragment of the link.
+// It returns the name of the file.
+func (c *Client) Download(ctx context.Context, link string, w io.Writer, cb func(Progress)) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", ErrInvalidLink
+	}
+
+	frag, _ := url.ParseQuery(u.Fragment)
+	key := frag.Get("key")
+	u.Fragment = ""
+
+	if u, err = c.resolve(ctx, u); err != nil {
+		return "", err
+	}
+
+	fileName := path.Base(u.Path)
+
+	res, err := c.get(ctx, u, key)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var r io.Reader = res.Body
+	total := res.ContentLength
+
+	params, err := e2e.ParamsFromMetadata(map[string]*string{
+		e2e.MetaCipher:    header(res.Header, "X-Amz-Meta-"+e2e.MetaCipher),
+		e2e.MetaChunkSize: header(res.Header, "X-Amz-Meta-"+e2e.MetaChunkSize),
+	})
+	if err != nil {
+		return "", err
+	} else if params != nil {
+		k, err := e2e.DecodeKey(key)
+		if err != nil {
+			return "", ErrMissingKey
+		}
+
+		if r, err = e2e.NewReader(r, k, params); err != nil {
+			return "", err
+		}
+
+		if total >= 0 {
+			if total, err = params.DecryptedSize(total); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	prog := &progress{
+		cb: cb,
+		Progress: Progress{
+			Total: total,
+		},
+	}
+
+	if _, err := io.Copy(w, &progressReader{r: r, prog: prog}); err != nil {
+		return "", err
+	}
+
+	return fileName, nil
+}
+
+// resolve follows the redirects of link shorteners until it reaches the download endpoint of GoSƐ.
+func (c *Client) resolve(ctx context.Context, u *url.URL) (*url.URL, error) {
+	for range maxRedirects {
+		if strings.Contains(u.Path, "/"+apiBase+"/download/") {
+			return u, nil
+		}
+
+		res, err := c.noRedirect(ctx, http.MethodGet, u)
+		if err != nil {
+			return nil, err
+		}
+		res.Body.Close()
+
+		loc, err := res.Location()
+		if err != nil {
+			return nil, ErrInvalidLink
+		}
+
+		u = loc
+	}
+
+	return nil, ErrInvalidLink
+}
+
+// get starts the download of a file from S3.
+func (c *Client) get(ctx context.Context, u *url.URL, key string) (*http.Response, error) {
+	raw := *u
+	q := raw.Query()
+	q.Set("raw", "1")
+	raw.RawQuery = q.Encode()
+
+	res, err := c.noRedirect(ctx, http.MethodGet, &raw)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var req *http.Request
+	switch {
+	case res.StatusCode >= 300 && res.StatusCode < 400:
+		loc, err := res.Location()
+		if err != nil {
+			return nil, err
+		}
+
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, loc.String(), nil); err != nil {
+			return nil, err
+		}
+
+	// Files encrypted with SSE-C are served by a landing page which requests the download with the key.
+	case res.StatusCode == http.StatusOK && strings.HasPrefix(res.Header.Get("Content-Type"), "text/html"):
+		if key == "" {
+			return nil, ErrMissingKey
+		}
+
+		var resp downloadKeyResponse
+		if err := c.requestURL(ctx, http.MethodPost, u.String(), &downloadKeyRequest{
+			Key: key,
+		}, &resp); err != nil {
+			return nil, err
+		}
+
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, resp.URL, nil); err != nil {
+			return nil, err
+		}
+
+		for k, v := range resp.Headers {
+			req.Header.Set(k, v)
+		}
+
+	default:
+		return nil, newError(res)
+	}
+
+	dl, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if dl.StatusCode != http.StatusOK {
+		dl.Body.Close()
+		return nil, fmt.Errorf("unexpected status: %s", dl.Status)
+	}
+
+	return dl, nil
+}
+
+// noRedirect sends a request without following redirects.
+func (c *Client) noRedirect(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	hc := *c.HTTP
+	hc.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return hc.Do(req)
+}
+
+func header(h http.Header, key string) *string {
+	if v := h.Get(key); v != "" {
+		return &v
+	}
+
+	return nil
+}
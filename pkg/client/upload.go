@@ -0,0 +1,540 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/stv0g/gose/pkg/e2e"
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+// AutoServer lets GoSƐ choose a healthy server of a group.
+const AutoServer = "auto"
+
+var (
+	// ErrEmptyFile is returned for empty files which can only be uploaded with end-to-end encryption.
+	ErrEmptyFile = errors.New("empty files can not be uploaded")
+
+	// ErrChecksumMismatch is returned if the data received by S3 differs from the local file.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// UploadOptions are the options of an upload.
+// They correspond to the settings of the web frontend.
+type UploadOptions struct {
+	// Server is the ID of a server or "auto". The first available server is used if empty.
+	Server string
+
+	// Group is the group of servers from which the "auto" server is chosen.
+	Group string
+
+	FileName string
+
+	// Type is the MIME type of the file. It is detected by GoSƐ if empty.
+	Type string
+
+	// Expiration is the ID of an expiration class. The default class of the server is used if empty.
+	Expiration string
+
+	ShortURL   bool
+	NotifyMail string
+
+	// Encrypt enables end-to-end encryption. The key is only included in the fragment of the returned URL.
+	Encrypt bool
+
+	// Progress is called whenever data has been uploaded.
+	Progress func(Progress)
+}
+
+// Progress of an upload or download.
+type Progress struct {
+	// Transferred is the number of bytes which have been transferred.
+	Transferred int64
+
+	// Skipped is the number of bytes of resumed parts which have not been uploaded again.
+	Skipped int64
+
+	// Total is the total number of bytes or -1 if unknown.
+	Total int64
+}
+
+// Result describes a completed upload.
+type Result struct {
+	Server string
+	ETag   string
+	URL    string
+
+	// OwnerToken allows to transfer the upload later.
+	OwnerToken string
+
+	// Existing is true if the file has already been uploaded before.
+	Existing bool
+}
+
+// Part is a part of a multi-part upload.
+type Part struct {
+	Number int64  `json:"number"`
+	ETag   string `json:"etag"`
+	Length int    `json:"length,omitempty"`
+
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type initiateRequest struct {
+	Server     string      `json:"server"`
+	Group      string      `json:"group,omitempty"`
+	ETag       string      `json:"etag"`
+	FileName   string      `json:"filename"`
+	ShortURL   bool        `json:"short_url"`
+	Type       string      `json:"type"`
+	Size       int64       `json:"size"`
+	Session    string      `json:"session,omitempty"`
+	Encryption *e2e.Params `json:"encryption,omitempty"`
+}
+
+type initiateResponse struct {
+	Server   string `json:"server"`
+	ETag     string `json:"etag"`
+	URL      string `json:"url"`
+	UploadID string `json:"upload_id"`
+	Session  string `json:"session"`
+	Parts    []Part `json:"parts"`
+}
+
+type partRequest struct {
+	Server   string `json:"server"`
+	ETag     string `json:"etag"`
+	UploadID string `json:"upload_id"`
+	Session  string `json:"session"`
+	Number   int64  `json:"number"`
+	Length   int    `json:"length"`
+	Checksum string `json:"checksum"`
+}
+
+type partResponse struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+type completionRequest struct {
+	Server     string  `json:"server"`
+	ETag       string  `json:"etag"`
+	UploadID   string  `json:"upload_id"`
+	Session    string  `json:"session"`
+	Parts      []Part  `json:"parts"`
+	NotifyMail *string `json:"notify_mail"`
+	Expiration *string `json:"expiration"`
+}
+
+type completionResponse struct {
+	ETag       string `json:"etag"`
+	URL        string `json:"url"`
+	OwnerToken string `json:"owner_token"`
+}
+
+// source splits a file into the parts which are uploaded.
+// The parts of end-to-end encrypted files are encrypted chunks.
+type source struct {
+	r         io.ReaderAt
+	plainSize int64
+	partSize  int64
+	count     int64
+
+	chunks *e2e.Chunks
+	params *e2e.Params
+}
+
+func newSource(r io.ReaderAt, size, partSize int64, key []byte) (*source, error) {
+	s := &source{
+		r:         r,
+		plainSize: size,
+		partSize:  partSize,
+	}
+
+	if key == nil {
+		if size == 0 {
+			return nil, ErrEmptyFile
+		}
+
+		s.count = (size + partSize - 1) / partSize
+
+		return s, nil
+	}
+
+	var err error
+	if s.chunks, err = e2e.NewChunks(key); err != nil {
+		return nil, err
+	}
+
+	s.params = &e2e.Params{
+		Cipher:    e2e.Cipher,
+		ChunkSize: partSize,
+	}
+
+	s.count = max((size+s.params.PlainChunkSize()-1)/s.params.PlainChunkSize(), 1)
+
+	return s, nil
+}
+
+// Size returns the number of bytes which are uploaded.
+func (s *source) Size() int64 {
+	if s.params != nil {
+		return s.params.EncryptedSize(s.plainSize)
+	}
+
+	return s.plainSize
+}
+
+// Part returns the data of part i (starting at 0).
+func (s *source) Part(i int64) ([]byte, error) {
+	chunkSize := s.partSize
+	if s.params != nil {
+		chunkSize = s.params.PlainChunkSize()
+	}
+
+	off := i * chunkSize
+	buf := make([]byte, min(chunkSize, s.plainSize-off))
+	if _, err := s.r.ReadAt(buf, off); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if s.chunks != nil {
+		buf = s.chunks.Seal(buf[:0], buf, uint64(i), i == s.count-1)
+	}
+
+	return buf, nil
+}
+
+// Hash calculates the parts and the ETag of the uploaded data.
+func (s *source) Hash() ([]Part, string, error) {
+	parts := []Part{}
+	etags := []string{}
+
+	for i := range s.count {
+		data, err := s.Part(i)
+		if err != nil {
+			return nil, "", err
+		}
+
+		sum := md5.Sum(data)
+		etag := hex.EncodeToString(sum[:])
+
+		parts = append(parts, Part{
+			Number: i + 1,
+			ETag:   etag,
+			Length: len(data),
+		})
+		etags = append(etags, etag)
+	}
+
+	etag, err := utils.MultipartETag(etags)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return parts, etag, nil
+}
+
+// Upload uploads a file.
+// Interrupted uploads are resumed if the client has a session store.
+// End-to-end encrypted uploads are never resumed as they use a new key each time.
+func (c *Client) Upload(ctx context.Context, r io.ReaderAt, size int64, opts *UploadOptions) (*Result, error) {
+	cfg, err := c.Config(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configuration: %w", err)
+	}
+
+	svr, err := selectServer(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	if opts.Encrypt {
+		if key, err = e2e.NewKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	src, err := newSource(r, size, svr.PartSize, key)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, etag, err := src.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	sessionKey := fmt.Sprintf("session-%s-%s", svr.ID, etag)
+
+	var respInitiate initiateResponse
+	if err := c.request(ctx, http.MethodPost, "initiate", &initiateRequest{
+		Server:     svr.ID,
+		Group:      opts.Group,
+		ETag:       etag,
+		FileName:   opts.FileName,
+		ShortURL:   opts.ShortURL,
+		Type:       opts.Type,
+		Size:       src.Size(),
+		Session:    c.session(sessionKey),
+		Encryption: src.params,
+	}, &respInitiate); err != nil {
+		return nil, fmt.Errorf("failed to initiate upload: %w", err)
+	}
+
+	res := &Result{
+		Server: respInitiate.Server,
+		ETag:   respInitiate.ETag,
+		URL:    respInitiate.URL,
+	}
+
+	if res.Server == "" {
+		res.Server = svr.ID
+	}
+
+	if respInitiate.UploadID == "" {
+		res.Existing = true
+		return res, nil
+	}
+
+	if c.Sessions != nil {
+		c.Sessions.Set(sessionKey, respInitiate.Session)
+	}
+
+	// The server might have been chosen by GoSƐ.
+	if s := cfg.Server(res.Server); s != nil {
+		svr = s
+	}
+
+	if err := c.uploadParts(ctx, src, svr, parts, &respInitiate, opts.Progress); err != nil {
+		return nil, err
+	}
+
+	req := &completionRequest{
+		Server:   res.Server,
+		ETag:     respInitiate.ETag,
+		UploadID: respInitiate.UploadID,
+		Session:  respInitiate.Session,
+		Parts:    parts,
+	}
+
+	if opts.Expiration != "" {
+		req.Expiration = &opts.Expiration
+	}
+
+	if opts.NotifyMail != "" {
+		req.NotifyMail = &opts.NotifyMail
+	}
+
+	var respComplete completionResponse
+	if err := c.request(ctx, http.MethodPost, "complete", req, &respComplete); err != nil {
+		return nil, fmt.Errorf("failed to complete upload: %w", err)
+	}
+
+	if c.Sessions != nil {
+		c.Sessions.Delete(sessionKey)
+	}
+
+	if respComplete.ETag != respInitiate.ETag {
+		return nil, ErrChecksumMismatch
+	}
+
+	res.OwnerToken = respComplete.OwnerToken
+
+	// We do not get a URL for resumed uploads from the initiate request.
+	if res.URL == "" {
+		res.URL = respComplete.URL
+	}
+
+	if key != nil {
+		res.URL += "#key=" + e2e.EncodeKey(key)
+	}
+
+	return res, nil
+}
+
+func (c *Client) session(key string) string {
+	if c.Sessions == nil {
+		return ""
+	}
+
+	return c.Sessions.Get(key)
+}
+
+// uploadParts uploads all parts which have not been uploaded before in parallel.
+func (c *Client) uploadParts(ctx context.Context, src *source, svr *Server, parts []Part, resp *initiateResponse, cb func(Progress)) error {
+	existing := map[int64]Part{}
+	for _, p := range resp.Parts {
+		existing[p.Number] = p
+	}
+
+	prog := &progress{
+		cb: cb,
+		Progress: Progress{
+			Total: src.Size(),
+		},
+	}
+
+	// ETags of parts encrypted with SSE-KMS or SSE-C are no MD5 digests.
+	// S3 checks the Content-MD5 header instead.
+	checkETag := svr.Encryption != "sse-kms" && svr.Encryption != "sse-c"
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(c.Concurrency, 1))
+
+	for _, part := range parts {
+		if e, ok := existing[part.Number]; ok && e.ETag == part.ETag && e.Length == part.Length {
+			prog.skip(int64(part.Length))
+			continue
+		}
+
+		g.Go(func() error {
+			data, err := src.Part(part.Number - 1)
+			if err != nil {
+				return err
+			}
+
+			var respPart partResponse
+			if err := c.request(ctx, http.MethodPost, "part", &partRequest{
+				Server:   resp.Server,
+				ETag:     resp.ETag,
+				UploadID: resp.UploadID,
+				Session:  resp.Session,
+				Number:   part.Number,
+				Length:   part.Length,
+				Checksum: part.ETag,
+			}, &respPart); err != nil {
+				return fmt.Errorf("failed to presign part %d: %w", part.Number, err)
+			}
+
+			etag, err := c.putPart(ctx, respPart.URL, respPart.Headers, data, prog)
+			if err != nil {
+				return fmt.Errorf("failed to upload part %d: %w", part.Number, err)
+			}
+
+			if checkETag && strings.Trim(etag, "\"") != part.ETag {
+				return fmt.Errorf("part %d: %w", part.Number, ErrChecksumMismatch)
+			}
+
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+func (c *Client) putPart(ctx context.Context, u string, hdrs map[string]string, data []byte, prog *progress) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, &progressReader{
+		r:    bytes.NewReader(data),
+		prog: prog,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req.ContentLength = int64(len(data))
+
+	for k, v := range hdrs {
+		req.Header.Set(k, v)
+	}
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status: %s", res.Status)
+	}
+
+	return res.Header.Get("ETag"), nil
+}
+
+// selectServer returns the server to which a file is uploaded.
+func selectServer(cfg *Config, opts *UploadOptions) (*Server, error) {
+	switch opts.Server {
+	case "":
+		for i := range cfg.Servers {
+			if cfg.Servers[i].Status != "down" {
+				return &cfg.Servers[i], nil
+			}
+		}
+
+	case AutoServer:
+		group := opts.Group
+		if group == "" {
+			group = "default"
+		}
+
+		// All servers of a group share the same part size.
+		for _, s := range cfg.Servers {
+			if s.Group == group {
+				s.ID = AutoServer
+				return &s, nil
+			}
+		}
+
+	default:
+		if s := cfg.Server(opts.Server); s != nil {
+			return s, nil
+		}
+	}
+
+	return nil, ErrNoServer
+}
+
+// progress tracks the progress of concurrent transfers.
+type progress struct {
+	Progress
+
+	cb func(Progress)
+	mu sync.Mutex
+}
+
+func (p *progress) add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Transferred += n
+	if p.cb != nil {
+		p.cb(p.Progress)
+	}
+}
+
+func (p *progress) skip(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Skipped += n
+	if p.cb != nil {
+		p.cb(p.Progress)
+	}
+}
+
+type progressReader struct {
+	r    io.Reader
+	prog *progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.prog.add(int64(n))
+
+	return n, err
+}
@@ -6,14 +6,15 @@ package config
 import (
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
 	units "github.com/docker/go-units"
-	"github.com/mitchellh/mapstructure"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/mozillazg/go-slugify"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -26,11 +27,35 @@ const (
 	// DefaultMaxUploadSize is the maximum upload size if not provided by the configuration.
 	DefaultMaxUploadSize size = 1 << 40 // 1TiB
 
+	// DefaultPresignValidity is the default validity of presigned part upload URLs.
+	DefaultPresignValidity = 1 * time.Hour
+
+	// DefaultSessionValidity is the default time after which upload sessions expire.
+	DefaultSessionValidity = 7 * 24 * time.Hour
+
+	// DefaultStateRefresh is the default interval in which shared state like block lists is reloaded.
+	DefaultStateRefresh = 1 * time.Minute
+
 	// DefaultRegion is the default S3 region if not provided by the configuration.
 	DefaultRegion = "us-east-1"
 
 	// DefaultBucket is the default S3 bucket name to use if not provided by the configuration.
 	DefaultBucket = "gose-uploads"
+
+	// DefaultGroup is the group of servers which do not specify one.
+	DefaultGroup = "default"
+
+	// EncryptionSSES3 encrypts objects with keys managed by S3.
+	EncryptionSSES3 = "sse-s3"
+
+	// EncryptionSSEKMS encrypts objects with keys managed by a KMS.
+	EncryptionSSEKMS = "sse-kms"
+
+	// EncryptionSSEC encrypts objects with a random key per upload which is only included in the download link.
+	EncryptionSSEC = "sse-c"
+
+	// AutoServer is the pseudo server ID which lets GoSƐ choose a healthy server of a group.
+	AutoServer = "auto"
 )
 
 // DefaultExpiration is list of default expiration classes.
@@ -82,6 +107,10 @@ type S3ServerConfig struct {
 	ID    string `json:"id" yaml:"id"`
 	Title string `json:"title" yaml:"title"`
 
+	// Group is the name of the group of servers among which uploads to the "auto" server are distributed.
+	// All servers of a group must use the same part size as it determines the ETag.
+	Group string `json:"group" yaml:"group"`
+
 	Implementation string       `json:"implementation" yaml:"implementation"`
 	MaxUploadSize  size         `json:"max_upload_size" yaml:"max_upload_size"`
 	PartSize       size         `json:"part_size" yaml:"part_size"`
@@ -96,6 +125,33 @@ type S3ServerSetup struct {
 	AbortIncompleteUploads int  `json:"abort_incomplete_uploads" yaml:"abort_incomplete_uploads"`
 }
 
+// PolicyConfig restricts the types of files which can be uploaded to a server.
+// MIME types can contain wildcards like "image/*".
+type PolicyConfig struct {
+	AllowedTypes      []string `json:"allowed_types" yaml:"allowed_types,omitempty"`
+	DeniedTypes       []string `json:"denied_types" yaml:"denied_types,omitempty"`
+	AllowedExtensions []string `json:"allowed_extensions" yaml:"allowed_extensions,omitempty"`
+	DeniedExtensions  []string `json:"denied_extensions" yaml:"denied_extensions,omitempty"`
+
+	// Sniff enables the detection of the actual content type after the upload has been completed.
+	Sniff bool `json:"sniff" yaml:"sniff"`
+}
+
+func (p *PolicyConfig) isEmpty() bool {
+	return len(p.AllowedTypes) == 0 && len(p.DeniedTypes) == 0 &&
+		len(p.AllowedExtensions) == 0 && len(p.DeniedExtensions) == 0 && !p.Sniff
+}
+
+// EncryptionConfig describes the server-side encryption of uploaded objects.
+type EncryptionConfig struct {
+	// Mode is one of "sse-s3", "sse-kms" or "sse-c".
+	Mode string `json:"mode" yaml:"mode"`
+
+	// KMSKeyID is the ID of the KMS key for "sse-kms".
+	// The default key of the bucket is used if empty.
+	KMSKeyID string `json:"kms_key_id" yaml:"kms_key_id,omitempty"`
+}
+
 // S3Server describes an S3 server
 type S3Server struct {
 	// S3ServerConfig is the public info about an S3 server shared with the frontend.
@@ -106,33 +162,198 @@ type S3Server struct {
 	Region    string `json:"region" yaml:"region"`
 	PathStyle bool   `json:"path_style" yaml:"path_style"`
 	NoSSL     bool   `json:"no_ssl" yaml:"no_ssl"`
-	AccessKey string `json:"access_key" yaml:"access_key"`
-	SecretKey string `json:"secret_key" yaml:"secret_key"`
+	AccessKey string `json:"access_key" yaml:"access_key" secret:"true"`
+	SecretKey string `json:"secret_key" yaml:"secret_key" secret:"true"`
+
+	Encryption *EncryptionConfig `json:"encryption" yaml:"encryption,omitempty"`
+
+	// Replicas are the IDs of servers to which completed uploads are copied.
+	// Downloads fall back to them if the object is not accessible on this server.
+	Replicas []string `json:"replicas" yaml:"replicas,omitempty"`
+
+	// Priority of the server within its group.
+	// Servers with a higher priority receive all automatically routed uploads as long as they are healthy.
+	Priority int `json:"priority" yaml:"priority"`
+
+	// Weight is the relative share of uploads among healthy servers of the same priority (defaults to 1).
+	Weight int `json:"weight" yaml:"weight"`
+
+	// PresignValidity is the maximum validity of presigned URLs for uploading parts.
+	PresignValidity time.Duration `json:"presign_validity" yaml:"presign_validity"`
+
+	// Checksums is a list of digest algorithms which are calculated for completed uploads.
+	Checksums []string `json:"checksums" yaml:"checksums"`
+
+	Policy PolicyConfig `json:"policy" yaml:"policy"`
 
 	Setup S3ServerSetup `json:"setup" yaml:"setup"`
 }
 
 // ShortenerConfig contains Link-shortener specific configuration.
 type ShortenerConfig struct {
-	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	Endpoint string `json:"endpoint" yaml:"endpoint" secret:"url"`
 	Method   string `json:"method" yaml:"method"`
 	Response string `json:"response" yaml:"response"`
 }
 
 // NotificationConfig contains notification specific configuration.
 type NotificationConfig struct {
-	URLs     []string `json:"urls" yaml:"urls"`
+	URLs     []string `json:"urls" yaml:"urls" secret:"url"`
 	Template string   `json:"template" yaml:"template"`
 
 	Uploads   bool `json:"uploads" yaml:"uploads"`
 	Downloads bool `json:"downloads" yaml:"downloads"`
 
 	Mail *struct {
-		URL      string `json:"url" yaml:"url"`
+		URL      string `json:"url" yaml:"url" secret:"url"`
 		Template string `json:"template" yaml:"template"`
 	} `json:"mail" yaml:"mail"`
 }
 
+// QuotaConfig contains limits per uploader.
+// A limit of zero disables the respective check.
+type QuotaConfig struct {
+	BytesPerDay       size `json:"bytes_per_day" yaml:"bytes_per_day"`
+	ConcurrentUploads int  `json:"concurrent_uploads" yaml:"concurrent_uploads"`
+	ActiveStorage     size `json:"active_storage" yaml:"active_storage"`
+}
+
+// RateLimit describes a token-bucket rate limit.
+type RateLimit struct {
+	// Rate is the number of requests per second.
+	Rate float64 `json:"rate" yaml:"rate"`
+
+	// Burst is the maximum number of requests which can be made at once.
+	Burst int `json:"burst" yaml:"burst"`
+}
+
+// RateLimitConfig contains the rate limits per client IP for different groups of routes.
+type RateLimitConfig struct {
+	API      *RateLimit `json:"api" yaml:"api,omitempty"`
+	Download *RateLimit `json:"download" yaml:"download,omitempty"`
+
+	// Redis is an optional URL of a Redis server to share the limits between replicas.
+	Redis string `json:"redis" yaml:"redis,omitempty" secret:"url"`
+}
+
+// ScannerConfig contains settings for scanning uploads with ClamAV.
+type ScannerConfig struct {
+	// Network is either "tcp" or "unix".
+	Network string        `json:"network" yaml:"network"`
+	Address string        `json:"address" yaml:"address"`
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+
+	// MaxSize is the size above which files are not scanned (0 for no limit).
+	// It should match the StreamMaxLength setting of clamd.
+	MaxSize size `json:"max_size" yaml:"max_size"`
+
+	// Action for infected files: "delete" or "quarantine".
+	Action string `json:"action" yaml:"action"`
+}
+
+// AdminConfig contains settings for the admin API.
+type AdminConfig struct {
+	// Accounts maps user names to passwords for HTTP basic authentication.
+	Accounts map[string]string `json:"accounts" yaml:"accounts" secret:"true"`
+}
+
+// BanConfig describes a statically configured ban.
+type BanConfig struct {
+	// Subject is either an IP address, a CIDR or an uploader identity.
+	Subject string `json:"subject" yaml:"subject"`
+	Reason  string `json:"reason" yaml:"reason"`
+
+	// Expires is an optional time after which the ban is lifted.
+	Expires time.Time `json:"expires" yaml:"expires,omitempty"`
+}
+
+// AuditConfig contains settings for the audit log.
+// Events are written as JSON lines to all configured destinations.
+type AuditConfig struct {
+	// File is the path of a file to which events are appended.
+	File string `json:"file" yaml:"file,omitempty"`
+
+	// Stdout writes events to the standard output.
+	Stdout bool `json:"stdout" yaml:"stdout"`
+
+	Bucket *AuditBucketConfig `json:"bucket" yaml:"bucket,omitempty"`
+}
+
+// AuditBucketConfig describes where audit events are stored in S3.
+type AuditBucketConfig struct {
+	// Server is the ID of the server in whose bucket the events are stored (defaults to the state server).
+	Server string `json:"server" yaml:"server"`
+
+	// Prefix is prepended to the keys of the objects containing the events.
+	Prefix string `json:"prefix" yaml:"prefix"`
+
+	// FlushInterval is the interval in which collected events are written to the bucket.
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"`
+}
+
+// LogConfig contains settings for logging.
+type LogConfig struct {
+	// Level is one of "debug", "info", "warn" or "error".
+	Level string `json:"level" yaml:"level"`
+
+	// Format is either "text" or "json".
+	Format string `json:"format" yaml:"format"`
+
+	// DumpConfig prints the configuration with redacted secrets at startup.
+	DumpConfig bool `json:"dump_config" yaml:"dump_config"`
+}
+
+// TracingConfig contains settings for exporting OpenTelemetry traces.
+type TracingConfig struct {
+	// Endpoint is the URL of an OTLP/HTTP collector like "http://localhost:4318".
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Headers are added to all export requests, e.g. for authentication.
+	Headers map[string]string `json:"headers" yaml:"headers,omitempty" secret:"true"`
+
+	ServiceName string `json:"service_name" yaml:"service_name"`
+
+	// SampleRatio is the fraction of traces which are sampled (0 to 1).
+	// Sampling decisions of upstream services are respected.
+	// It is a pointer to distinguish an explicit 0 from the default of 1.
+	SampleRatio *float64 `json:"sample_ratio" yaml:"sample_ratio"`
+}
+
+// HealthConfig contains settings for the background health checks reported by /readyz.
+type HealthConfig struct {
+	// Interval is the time between two checks of all components.
+	Interval time.Duration `json:"interval" yaml:"interval"`
+
+	// Timeout is the maximum duration of a single check.
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+
+	// CriticalServers are the IDs of the servers which must be healthy for GoSƐ to be ready.
+	// All servers are critical if empty.
+	CriticalServers []string `json:"critical_servers" yaml:"critical_servers,omitempty"`
+}
+
+// IsCritical returns true if the server with the given ID must be healthy for GoSƐ to be ready.
+func (h *HealthConfig) IsCritical(id string) bool {
+	if len(h.CriticalServers) == 0 {
+		return true
+	}
+
+	return slices.Contains(h.CriticalServers, id)
+}
+
+// StateConfig describes where GoSƐ persists its own state like quotas.
+type StateConfig struct {
+	// Server is the ID of the server in whose bucket the state is kept.
+	Server string `json:"server" yaml:"server"`
+
+	// Prefix is prepended to the keys of all state objects.
+	Prefix string `json:"prefix" yaml:"prefix"`
+
+	// Refresh is the interval in which shared state is reloaded.
+	// Changes by other replicas become effective after this interval.
+	Refresh time.Duration `json:"refresh" yaml:"refresh"`
+}
+
 // Config contains the main configuration.
 type Config struct {
 	*viper.Viper `json:"-" yaml:"-"`
@@ -152,6 +373,29 @@ type Config struct {
 	// BaseURL at which Gose is accessible.
 	BaseURL string `json:"base_url" yaml:"base_url,omitempty"`
 
+	// Secret is used to encrypt upload session tokens.
+	// All replicas must share the same secret.
+	Secret string `json:"secret" yaml:"secret,omitempty" secret:"true"`
+
+	// SessionValidity is the time after which upload sessions expire.
+	SessionValidity time.Duration `json:"session_validity" yaml:"session_validity,omitempty"`
+
+	// TrustedProxies is a list of IPs/CIDRs of reverse proxies whose forwarding headers are trusted.
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies,omitempty"`
+
+	RateLimit *RateLimitConfig `json:"rate_limit" yaml:"rate_limit,omitempty"`
+
+	Scanner *ScannerConfig `json:"scanner" yaml:"scanner,omitempty"`
+	Admin   *AdminConfig   `json:"admin" yaml:"admin,omitempty"`
+	Bans    []BanConfig    `json:"bans" yaml:"bans,omitempty"`
+	Audit   *AuditConfig   `json:"audit" yaml:"audit,omitempty"`
+	Log     LogConfig      `json:"log" yaml:"log"`
+	Tracing *TracingConfig `json:"tracing" yaml:"tracing,omitempty"`
+	Health  HealthConfig   `json:"health" yaml:"health"`
+
+	State StateConfig  `json:"state" yaml:"state"`
+	Quota *QuotaConfig `json:"quota" yaml:"quota,omitempty"`
+
 	Shortener    *ShortenerConfig    `json:"shortener" yaml:"shortener,omitempty"`
 	Notification *NotificationConfig `json:"notification" yaml:"notification,omitempty"`
 }
@@ -166,6 +410,16 @@ func NewConfig(configFile string) (*Config, error) {
 	cfg.SetDefault("listen", ":8080")
 	cfg.SetDefault("static", "./dist")
 	cfg.SetDefault("base_url", "http://localhost:8080")
+	cfg.SetDefault("secret", "")
+	cfg.SetDefault("session_validity", DefaultSessionValidity)
+	cfg.SetDefault("state.server", "")
+	cfg.SetDefault("state.prefix", ".gose/")
+	cfg.SetDefault("state.refresh", DefaultStateRefresh)
+	cfg.SetDefault("log.level", "info")
+	cfg.SetDefault("log.format", "text")
+	cfg.SetDefault("log.dump_config", true)
+	cfg.SetDefault("health.interval", 30*time.Second)
+	cfg.SetDefault("health.timeout", 5*time.Second)
 	cfg.SetDefault("notification.uploads", true)
 	cfg.SetDefault("notification.downloads", false)
 	cfg.SetDefault("max_upload_size", DefaultMaxUploadSize)
@@ -179,6 +433,8 @@ func NewConfig(configFile string) (*Config, error) {
 	cfg.SetDefault("access_key", "")
 	cfg.SetDefault("secret_key", "")
 	cfg.SetDefault("implementation", "")
+	cfg.SetDefault("checksums", []string{"sha256"})
+	cfg.SetDefault("presign_validity", DefaultPresignValidity)
 	cfg.SetDefault("setup.bucket", true)
 	cfg.SetDefault("setup.cors", true)
 	cfg.SetDefault("setup.lifecycle", true)
@@ -200,10 +456,15 @@ func NewConfig(configFile string) (*Config, error) {
 		}
 	}
 
-	if err := cfg.UnmarshalExact(cfg, viper.DecodeHook(func(c *mapstructure.DecoderConfig) {
-		c.DecodeHook = mapstructure.TextUnmarshallerHookFunc()
+	if err := cfg.UnmarshalExact(cfg, func(c *mapstructure.DecoderConfig) {
+		c.DecodeHook = mapstructure.ComposeDecodeHookFunc(
+			secretsHook,
+			mapstructure.TextUnmarshallerHookFunc(),
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToTimeHookFunc(time.RFC3339),
+		)
 		c.TagName = "json"
-	})); err != nil {
+	}); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
@@ -224,6 +485,14 @@ func NewConfig(configFile string) (*Config, error) {
 			svr.Title = svr.Endpoint
 		}
 
+		if svr.Group == "" {
+			svr.Group = DefaultGroup
+		}
+
+		if svr.Weight == 0 {
+			svr.Weight = 1
+		}
+
 		if svr.Region == "" {
 			svr.Region = cfg.Region
 		}
@@ -247,26 +516,195 @@ func NewConfig(configFile string) (*Config, error) {
 		if svr.Expiration == nil {
 			svr.Expiration = []Expiration{}
 		}
+
+		if svr.Policy.isEmpty() {
+			svr.Policy = cfg.Policy
+		}
+
+		if svr.PresignValidity == 0 {
+			svr.PresignValidity = cfg.PresignValidity
+		}
+
+		if svr.Encryption == nil {
+			svr.Encryption = cfg.Encryption
+		}
+
+		if svr.Checksums == nil {
+			svr.Checksums = cfg.Checksums
+		}
+	}
+
+	if cfg.Scanner != nil {
+		if cfg.Scanner.Network == "" {
+			cfg.Scanner.Network = "tcp"
+		}
+
+		if cfg.Scanner.Timeout == 0 {
+			cfg.Scanner.Timeout = 30 * time.Second
+		}
+
+		if cfg.Scanner.Action == "" {
+			cfg.Scanner.Action = "quarantine"
+		}
+	}
+
+	// Keep state in the first server by default.
+	if cfg.State.Server == "" {
+		cfg.State.Server = cfg.Servers[0].ID
+	}
+
+	if cfg.Tracing != nil {
+		if cfg.Tracing.ServiceName == "" {
+			cfg.Tracing.ServiceName = "gose"
+		}
+
+		if cfg.Tracing.SampleRatio == nil {
+			ratio := 1.0
+			cfg.Tracing.SampleRatio = &ratio
+		}
+	}
+
+	if cfg.Audit != nil && cfg.Audit.Bucket != nil {
+		if cfg.Audit.Bucket.Server == "" {
+			cfg.Audit.Bucket.Server = cfg.State.Server
+		}
+
+		if cfg.Audit.Bucket.Prefix == "" {
+			cfg.Audit.Bucket.Prefix = cfg.State.Prefix + "audit/"
+		}
+
+		if cfg.Audit.Bucket.FlushInterval == 0 {
+			cfg.Audit.Bucket.FlushInterval = time.Minute
+		}
 	}
 
 	if err := cfg.Check(); err != nil {
 		return nil, fmt.Errorf("failed to load configuration: %w", err)
 	}
 
-	log.Printf("Loaded configuration:\n")
-	bs, _ := yaml.Marshal(cfg)
-	fmt.Print(string(bs))
+	slog.Info("Loaded configuration")
 
 	return cfg, nil
 }
 
 func (c *Config) Check() error {
+	stateServerFound := false
+	auditServerFound := c.Audit == nil || c.Audit.Bucket == nil
+	groupPartSizes := map[string]size{}
+
 	for _, svr := range c.Servers {
+		if svr.ID == AutoServer {
+			return fmt.Errorf("server ID %s is reserved", AutoServer)
+		}
+
+		if svr.Weight < 0 {
+			return fmt.Errorf("server %s has a negative weight", svr.ID)
+		}
+
+		for _, id := range svr.Replicas {
+			i := slices.IndexFunc(c.Servers, func(o S3Server) bool { return o.ID == id })
+			if id == svr.ID || i < 0 {
+				return fmt.Errorf("invalid replica of server %s: %s", svr.ID, id)
+			}
+
+			if e := c.Servers[i].Encryption; e != nil && e.Mode == EncryptionSSEC {
+				return fmt.Errorf("replica %s of server %s uses sse-c", id, svr.ID)
+			}
+		}
+
+		if err := c.checkEncryption(&svr); err != nil {
+			return fmt.Errorf("server %s: %w", svr.ID, err)
+		}
+
+		if ps, ok := groupPartSizes[svr.Group]; ok && ps != svr.PartSize {
+			return fmt.Errorf("servers of group %s must have the same part_size", svr.Group)
+		}
+		groupPartSizes[svr.Group] = svr.PartSize
+
 		if svr.PartSize < MinPartSize {
 			return fmt.Errorf("part_size must be larger than %s (it is currently %s)",
 				units.HumanSize(float64(MinPartSize)),
 				units.HumanSize(float64(svr.PartSize)))
 		}
+
+		if svr.ID == c.State.Server {
+			stateServerFound = true
+		}
+
+		if c.Audit != nil && c.Audit.Bucket != nil && svr.ID == c.Audit.Bucket.Server {
+			auditServerFound = true
+		}
+
+		for _, algo := range svr.Checksums {
+			if algo != "sha256" && algo != "sha512" {
+				return fmt.Errorf("unsupported checksum algorithm: %s", algo)
+			}
+		}
+	}
+
+	if rl := c.RateLimit; rl != nil {
+		for _, l := range []*RateLimit{rl.API, rl.Download} {
+			if l != nil && (l.Rate <= 0 || l.Burst < 1) {
+				return fmt.Errorf("rate limits must have a positive rate and burst")
+			}
+		}
+	}
+
+	if sc := c.Scanner; sc != nil {
+		if sc.Network != "tcp" && sc.Network != "unix" {
+			return fmt.Errorf("scanner network must be either tcp or unix")
+		}
+
+		if sc.Action != "delete" && sc.Action != "quarantine" {
+			return fmt.Errorf("scanner action must be either delete or quarantine")
+		}
+	}
+
+	if t := c.Tracing; t != nil {
+		if t.Endpoint == "" {
+			return fmt.Errorf("tracing requires an endpoint")
+		}
+
+		if r := t.SampleRatio; r != nil && (*r < 0 || *r > 1) {
+			return fmt.Errorf("tracing sample ratio must be between 0 and 1")
+		}
+	}
+
+	if c.Health.Interval <= 0 || c.Health.Timeout <= 0 {
+		return fmt.Errorf("health check interval and timeout must be positive")
+	}
+
+	for _, id := range c.Health.CriticalServers {
+		if !slices.ContainsFunc(c.Servers, func(svr S3Server) bool { return svr.ID == id }) {
+			return fmt.Errorf("unknown critical server: %s", id)
+		}
+	}
+
+	for _, b := range c.Bans {
+		if strings.TrimSpace(b.Subject) == "" {
+			return fmt.Errorf("ban without subject")
+		}
+	}
+
+	if c.Admin != nil && len(c.Admin.Accounts) == 0 {
+		return fmt.Errorf("admin API requires at least one account")
+	}
+
+	if c.Admin != nil {
+		for user, pass := range c.Admin.Accounts {
+			// Reject empty passwords and placeholders like <your-password>
+			if p := strings.TrimSpace(pass); p == "" || strings.HasPrefix(p, "<") && strings.HasSuffix(p, ">") {
+				return fmt.Errorf("admin account %s has no password", user)
+			}
+		}
+	}
+
+	if !stateServerFound {
+		return fmt.Errorf("unknown state server: %s", c.State.Server)
+	}
+
+	if !auditServerFound {
+		return fmt.Errorf("unknown audit server: %s", c.Audit.Bucket.Server)
 	}
 
 	return nil
@@ -292,3 +730,36 @@ func ParseFlags() (string, bool, error) {
 	// Return the configuration path.
 	return configPath, showVersion, nil
 }
+
+// checkEncryption validates the encryption settings of a server.
+// GoSƐ does not know the keys of SSE-C objects after their upload.
+// So features which process their content are not supported.
+func (c *Config) checkEncryption(svr *S3Server) error {
+	e := svr.Encryption
+	if e == nil {
+		return nil
+	}
+
+	switch e.Mode {
+	case EncryptionSSES3, EncryptionSSEKMS:
+	case EncryptionSSEC:
+		switch {
+		case svr.NoSSL:
+			return fmt.Errorf("sse-c requires TLS")
+		case svr.Policy.Sniff:
+			return fmt.Errorf("sse-c does not support content sniffing")
+		case len(svr.Replicas) > 0:
+			return fmt.Errorf("sse-c does not support replication")
+		case c.Scanner != nil:
+			return fmt.Errorf("sse-c does not support malware scanning")
+		}
+	default:
+		return fmt.Errorf("invalid encryption mode: %s", e.Mode)
+	}
+
+	if e.KMSKeyID != "" && e.Mode != EncryptionSSEKMS {
+		return fmt.Errorf("kms_key_id requires sse-kms")
+	}
+
+	return nil
+}
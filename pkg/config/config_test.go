@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/config"
+)
+
+func TestTracingSampleRatio(t *testing.T) {
+	for content, want := range map[string]float64{
+		"tracing:\n  endpoint: http://localhost:4318\n":                      1,
+		"tracing:\n  endpoint: http://localhost:4318\n  sample_ratio: 0\n":   0,
+		"tracing:\n  endpoint: http://localhost:4318\n  sample_ratio: 0.5\n": 0.5,
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write file: %s", err)
+		}
+
+		cfg, err := config.NewConfig(path)
+		if err != nil {
+			t.Fatalf("Failed to load configuration: %s", err)
+		}
+
+		if got := cfg.Tracing.SampleRatio; got == nil || *got != want {
+			t.Errorf("Unexpected sample ratio for %q: %v, want %v", content, got, want)
+		}
+
+		if got := cfg.Redacted().Tracing.SampleRatio; got == nil || *got != want {
+			t.Errorf("Unexpected redacted sample ratio for %q: %v, want %v", content, got, want)
+		}
+	}
+}
+
+func TestAdminPassword(t *testing.T) {
+	for pass, valid := range map[string]bool{
+		`""`:              false,
+		`"  "`:            false,
+		`<your-password>`: false,
+		`s3cr3t`:          true,
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte("admin:\n  accounts:\n    admin: "+pass+"\n"), 0o600); err != nil {
+			t.Fatalf("Failed to write file: %s", err)
+		}
+
+		if _, err := config.NewConfig(path); (err == nil) != valid {
+			t.Errorf("Unexpected result for password %s: %v", pass, err)
+		}
+	}
+}
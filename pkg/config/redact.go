@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secrets in the redacted copy of the configuration.
+const Redacted = "REDACTED"
+
+// Fields containing secrets are marked with a struct tag:
+//
+//	secret:"true" replaces the whole value
+//	secret:"url"  replaces the password of the user info and sensitive query parameters
+//	              or everything after the scheme for other than well-known schemes
+//
+// For maps, the tag applies to their values.
+const secretTag = "secret"
+
+// plainSchemes are URL schemes which do not carry secrets in their host or path.
+// Others, like the service URLs of shoutrrr (slack://token-a/token-b/token-c), commonly do.
+var plainSchemes = []string{"http", "https", "redis", "rediss", "smtp", "smtps"}
+
+// secretParams are substrings of the names of query parameters whose values are redacted.
+var secretParams = []string{"key", "token", "secret", "pass", "auth", "sig"}
+
+// Redacted returns a deep copy of the configuration with all secrets replaced.
+func (c *Config) Redacted() *Config {
+	r := redactValue(reflect.ValueOf(c), "").Interface().(*Config)
+	r.Viper = nil
+
+	return r
+}
+
+// Dump writes the redacted configuration as YAML.
+func (c *Config) Dump(w io.Writer) error {
+	bs, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(bs)
+	return err
+}
+
+// redactValue returns a copy of v in which all strings are redacted according to the secret tag mode.
+func redactValue(v reflect.Value, mode string) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+
+		// Other packages are not traversed (e.g. *viper.Viper).
+		if t := v.Elem().Type(); t.Kind() == reflect.Struct && t.Name() != "" && t.PkgPath() != reflect.TypeFor[Config]().PkgPath() {
+			return v
+		}
+
+		p := reflect.New(v.Elem().Type())
+		p.Elem().Set(redactValue(v.Elem(), mode))
+		return p
+
+	case reflect.Struct:
+		s := reflect.New(v.Type()).Elem()
+		s.Set(v)
+
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			s.Field(i).Set(redactValue(v.Field(i), f.Tag.Get(secretTag)))
+		}
+
+		return s
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			s.Index(i).Set(redactValue(v.Index(i), mode))
+		}
+
+		return s
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for it := v.MapRange(); it.Next(); {
+			m.SetMapIndex(it.Key(), redactValue(it.Value(), mode))
+		}
+
+		return m
+
+	case reflect.String:
+		s := reflect.New(v.Type()).Elem()
+		s.SetString(redactString(v.String(), mode))
+		return s
+	}
+
+	return v
+}
+
+func redactString(s, mode string) string {
+	switch {
+	case s == "":
+		return s
+	case mode == "true":
+		return Redacted
+	case mode == "url":
+		return RedactURL(s)
+	}
+
+	return s
+}
+
+// RedactURL replaces the password of the user info and the values of sensitive query parameters of a URL.
+// If the user info has no password, the user name is replaced as it commonly is an API token.
+// Everything after the scheme is replaced for schemes other than plainSchemes.
+// The URL is not parsed strictly, as it might be a template.
+func RedactURL(s string) string {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok {
+		return s
+	}
+
+	if !slices.Contains(plainSchemes, strings.ToLower(scheme)) {
+		return scheme + "://" + Redacted
+	}
+
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+
+	authority, tail := rest[:end], rest[end:]
+
+	if at := strings.LastIndex(authority, "@"); at >= 0 {
+		userinfo, host := authority[:at], authority[at+1:]
+
+		if user, _, ok := strings.Cut(userinfo, ":"); ok {
+			userinfo = user + ":" + Redacted
+		} else {
+			userinfo = Redacted
+		}
+
+		authority = userinfo + "@" + host
+	}
+
+	path, query, ok := strings.Cut(tail, "?")
+	if ok {
+		query, frag, hasFrag := strings.Cut(query, "#")
+
+		params := strings.Split(query, "&")
+		for i, p := range params {
+			if name, _, ok := strings.Cut(p, "="); ok && isSecretParam(name) {
+				params[i] = name + "=" + Redacted
+			}
+		}
+
+		tail = path + "?" + strings.Join(params, "&")
+		if hasFrag {
+			tail += "#" + frag
+		}
+	}
+
+	return scheme + "://" + authority + tail
+}
+
+func isSecretParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range secretParams {
+		if strings.Contains(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,228 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fileSuffix is appended to the key of a secret field to read its value from a file.
+const fileSuffix = "_file"
+
+// Resolver resolves references to secrets like "scheme://ref".
+type Resolver interface {
+	// Resolve returns the secret for the part of the reference following "scheme://".
+	Resolve(ref string) (string, error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(ref string) (string, error)
+
+func (f ResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+var (
+	resolversLock sync.RWMutex
+	resolvers     = map[string]Resolver{
+		"file": ResolverFunc(resolveFile),
+		"env":  ResolverFunc(resolveEnv),
+	}
+)
+
+func init() {
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		RegisterResolver("vault", NewVaultResolver(addr, os.Getenv("VAULT_TOKEN")))
+	}
+}
+
+// RegisterResolver registers a resolver for references with the given scheme.
+func RegisterResolver(scheme string, r Resolver) {
+	resolversLock.Lock()
+	defer resolversLock.Unlock()
+
+	resolvers[scheme] = r
+}
+
+// ResolveSecret resolves a reference to a secret.
+// Values which are not a reference with a registered scheme are returned unchanged.
+func ResolveSecret(value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+
+	resolversLock.RLock()
+	r, ok := resolvers[scheme]
+	resolversLock.RUnlock()
+
+	if !ok {
+		return value, nil
+	}
+
+	secret, err := r.Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s://%s: %w", scheme, ref, err)
+	}
+
+	return secret, nil
+}
+
+func resolveFile(path string) (string, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(bs), "\r\n"), nil
+}
+
+func resolveEnv(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return v, nil
+}
+
+// secretsHook is a decode hook which loads secret fields of structs from "_file" keys and resolves references in them.
+func secretsHook(from, to reflect.Value) (any, error) {
+	m, ok := from.Interface().(map[string]any)
+	if !ok || to.Kind() != reflect.Struct {
+		return from.Interface(), nil
+	}
+
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+
+	if err := resolveSecrets(out, to.Type()); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func resolveSecrets(m map[string]any, t reflect.Type) error {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+		// Squashed structs share the map of their parent.
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && opts == "squash" {
+			if err := resolveSecrets(m, f.Type); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if f.Tag.Get(secretTag) == "" || name == "" || name == "-" {
+			continue
+		}
+
+		if path, ok := m[name+fileSuffix]; ok {
+			delete(m, name+fileSuffix)
+
+			if v, err := readSecretFile(path, f.Type); err != nil {
+				return fmt.Errorf("%s%s: %w", name, fileSuffix, err)
+			} else if v != nil {
+				m[name] = v
+			}
+		}
+
+		v, err := resolveValue(m[name])
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if v != nil {
+			m[name] = v
+		}
+	}
+
+	return nil
+}
+
+// readSecretFile reads the value of a secret field from a file.
+// Lists are read line by line.
+func readSecretFile(path any, t reflect.Type) (any, error) {
+	p, ok := path.(string)
+	if !ok {
+		return nil, fmt.Errorf("path must be a string")
+	} else if p == "" {
+		return nil, nil
+	}
+
+	s, err := resolveFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return s, nil
+
+	case reflect.Slice:
+		lines := []any{}
+		for _, l := range strings.Split(s, "\n") {
+			if l = strings.TrimSpace(l); l != "" {
+				lines = append(lines, l)
+			}
+		}
+
+		return lines, nil
+	}
+
+	return nil, fmt.Errorf("not supported for %s", t.Kind())
+}
+
+// resolveValue resolves references in strings and the elements of lists and maps.
+func resolveValue(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return ResolveSecret(v)
+
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			var err error
+			if out[i], err = resolveValue(e); err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+
+	case []string:
+		out := make([]any, len(v))
+		for i, e := range v {
+			var err error
+			if out[i], err = ResolveSecret(e); err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			var err error
+			if out[k], err = resolveValue(e); err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	}
+
+	return v, nil
+}
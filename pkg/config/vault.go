@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultResolver resolves references like "vault://secret/data/gose#secret_key"
+// by reading the field of a secret from the HTTP API of a HashiCorp Vault compatible server.
+// Both versions of the key/value secrets engine are supported.
+type VaultResolver struct {
+	Address string
+	Token   string
+	HTTP    *http.Client
+
+	mu      sync.Mutex
+	secrets map[string]map[string]any
+}
+
+// NewVaultResolver returns a resolver for the Vault server at the given address.
+func NewVaultResolver(addr, token string) *VaultResolver {
+	return &VaultResolver{
+		Address: strings.TrimSuffix(addr, "/"),
+		Token:   token,
+		HTTP: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		secrets: map[string]map[string]any{},
+	}
+}
+
+// Resolve returns a field of a secret.
+// Secrets are only fetched once.
+func (r *VaultResolver) Resolve(ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || field == "" {
+		return "", fmt.Errorf("missing field")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, ok := r.secrets[path]
+	if !ok {
+		var err error
+		if data, err = r.read(path); err != nil {
+			return "", err
+		}
+
+		r.secrets[path] = data
+	}
+
+	v, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("secret has no field %s", field)
+	}
+
+	return v, nil
+}
+
+func (r *VaultResolver) read(path string) (map[string]any, error) {
+	req, err := http.NewRequest(http.MethodGet, r.Address+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Token != "" {
+		req.Header.Set("X-Vault-Token", r.Token)
+	}
+
+	resp, err := r.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("failed to decode secret: %w", err)
+	}
+
+	// Version 2 of the key/value engine nests the data along with its metadata.
+	if data, ok := secret.Data["data"].(map[string]any); ok {
+		if _, ok := secret.Data["metadata"]; ok {
+			return data, nil
+		}
+	}
+
+	return secret.Data, nil
+}
@@ -0,0 +1,214 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package e2e implements the format of end-to-end encrypted uploads.
+//
+// Files are encrypted by the uploader before they leave the client.
+// GoSƐ and the S3 server only see the ciphertext, the file name, its type and its size.
+// The key is only part of the fragment of the download link (#key=...) which browsers never send to a server.
+//
+// # Format
+//
+// The plaintext is split into chunks of ChunkSize-TagSize bytes.
+// Each chunk is encrypted with AES-256-GCM and stored as exactly ChunkSize bytes (ciphertext followed by the tag).
+// Only the final chunk may be shorter. An empty file consists of a single empty final chunk.
+// The chunk size equals the part size of the server so that each chunk is uploaded as a single part.
+//
+// Each file is encrypted with a new random 256-bit key.
+// The 96-bit nonce of chunk i is the big-endian 88-bit counter i followed by a single byte
+// which is 1 for the final chunk and 0 otherwise (STREAM construction).
+// This prevents the reordering and truncation of chunks.
+// No associated data is used.
+//
+// The key is encoded with unpadded URL-safe base64.
+// The cipher and chunk size are stored in the object metadata (see Params).
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// Cipher is the identifier of the only supported cipher.
+	Cipher = "aes-256-gcm-stream"
+
+	// KeySize is the size of the keys in bytes.
+	KeySize = 32
+
+	// TagSize is the size of the authentication tag of each chunk in bytes.
+	TagSize = 16
+
+	// NonceSize is the size of the nonce of each chunk in bytes.
+	NonceSize = 12
+
+	// MetaCipher and MetaChunkSize are the metadata keys of encrypted objects.
+	MetaCipher    = "Encryption-Cipher"
+	MetaChunkSize = "Encryption-Chunk-Size"
+)
+
+var (
+	// ErrInvalidKey is returned for malformed keys.
+	ErrInvalidKey = errors.New("invalid encryption key")
+
+	// ErrInvalidParams is returned for unsupported cipher parameters.
+	ErrInvalidParams = errors.New("invalid encryption parameters")
+
+	// ErrAuthentication is returned if a chunk has been modified, reordered or truncated or the key is wrong.
+	ErrAuthentication = errors.New("message authentication failed")
+)
+
+// Params describes the encryption of an object.
+type Params struct {
+	Cipher string `json:"cipher"`
+
+	// ChunkSize is the size of the encrypted chunks in bytes.
+	ChunkSize int64 `json:"chunk_size"`
+}
+
+// Check validates the parameters for a server with the given part size.
+func (p *Params) Check(partSize int64) error {
+	if p.Cipher != Cipher || p.ChunkSize != partSize || p.ChunkSize <= TagSize {
+		return ErrInvalidParams
+	}
+
+	return nil
+}
+
+// Metadata returns the S3 object metadata describing the encryption.
+func (p *Params) Metadata() map[string]string {
+	return map[string]string{
+		MetaCipher:    p.Cipher,
+		MetaChunkSize: strconv.FormatInt(p.ChunkSize, 10),
+	}
+}
+
+// ParamsFromMetadata returns the encryption parameters of an object or nil if it is not encrypted.
+func ParamsFromMetadata(meta map[string]*string) (*Params, error) {
+	c, ok := meta[MetaCipher]
+	if !ok || c == nil {
+		return nil, nil
+	}
+
+	p := &Params{
+		Cipher: *c,
+	}
+
+	if cs, ok := meta[MetaChunkSize]; ok && cs != nil {
+		var err error
+		if p.ChunkSize, err = strconv.ParseInt(*cs, 10, 64); err != nil {
+			return nil, ErrInvalidParams
+		}
+	}
+
+	if p.Cipher != Cipher || p.ChunkSize <= TagSize {
+		return nil, ErrInvalidParams
+	}
+
+	return p, nil
+}
+
+// PlainChunkSize returns the size of the plaintext chunks.
+func (p *Params) PlainChunkSize() int64 {
+	return p.ChunkSize - TagSize
+}
+
+// EncryptedSize returns the size of the ciphertext of a plaintext with the given size.
+func (p *Params) EncryptedSize(size int64) int64 {
+	chunks := max((size+p.PlainChunkSize()-1)/p.PlainChunkSize(), 1)
+
+	return size + chunks*TagSize
+}
+
+// DecryptedSize returns the size of the plaintext of a ciphertext with the given size.
+func (p *Params) DecryptedSize(size int64) (int64, error) {
+	chunks := (size + p.ChunkSize - 1) / p.ChunkSize
+	if chunks == 0 || size-(chunks-1)*p.ChunkSize < TagSize {
+		return 0, fmt.Errorf("invalid ciphertext size: %d", size)
+	}
+
+	return size - chunks*TagSize, nil
+}
+
+// NewKey generates a random key.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// EncodeKey encodes a key for the use in the fragment of links.
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeKey decodes a key from the fragment of a link.
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+// Nonce returns the nonce of a chunk.
+func Nonce(index uint64, final bool) []byte {
+	nonce := make([]byte, NonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], index)
+
+	if final {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
+
+// Chunks encrypts and decrypts single chunks.
+// It is used by clients which upload the chunks as separate parts.
+type Chunks struct {
+	aead cipher.AEAD
+}
+
+// NewChunks creates a new chunk cipher with the given key.
+func NewChunks(key []byte) (*Chunks, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chunks{aead}, nil
+}
+
+// Seal appends the encrypted chunk to dst.
+func (c *Chunks) Seal(dst, plain []byte, index uint64, final bool) []byte {
+	return c.aead.Seal(dst, Nonce(index, final), plain, nil)
+}
+
+// Open appends the decrypted chunk to dst.
+func (c *Chunks) Open(dst, chunk []byte, index uint64, final bool) ([]byte, error) {
+	plain, err := c.aead.Open(dst, Nonce(index, final), chunk, nil)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+
+	return plain, nil
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package e2e_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/e2e"
+)
+
+func TestStream(t *testing.T) {
+	p := &e2e.Params{
+		Cipher:    e2e.Cipher,
+		ChunkSize: 64,
+	}
+
+	key, err := e2e.NewKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+
+	for _, size := range []int{0, 1, 47, 48, 49, 96, 100} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		var ct bytes.Buffer
+		w, err := e2e.NewWriter(&ct, key, p)
+		if err != nil {
+			t.Fatalf("Failed to create writer: %s", err)
+		}
+
+		// Write in small pieces to cross chunk boundaries.
+		for i := 0; i < size; i += 5 {
+			w.Write(plain[i:min(i+5, size)])
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("Failed to close writer: %s", err)
+		}
+
+		if int64(ct.Len()) != p.EncryptedSize(int64(size)) {
+			t.Fatalf("Size mismatch for %d bytes: %d != %d", size, ct.Len(), p.EncryptedSize(int64(size)))
+		}
+
+		if ds, err := p.DecryptedSize(int64(ct.Len())); err != nil || ds != int64(size) {
+			t.Fatalf("Decrypted size mismatch for %d bytes: %d", size, ds)
+		}
+
+		r, _ := e2e.NewReader(bytes.NewReader(ct.Bytes()), key, p)
+		dec, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Failed to decrypt %d bytes: %s", size, err)
+		}
+
+		if !bytes.Equal(dec, plain) {
+			t.Fatalf("Plaintext mismatch for %d bytes", size)
+		}
+
+		// Truncating the ciphertext at a chunk boundary must be detected.
+		if ct.Len() > int(p.ChunkSize) {
+			r, _ := e2e.NewReader(bytes.NewReader(ct.Bytes()[:p.ChunkSize]), key, p)
+			if _, err := io.ReadAll(r); !errors.Is(err, e2e.ErrAuthentication) {
+				t.Fatalf("Expected authentication error for truncated ciphertext: %v", err)
+			}
+		}
+	}
+}
+
+func TestParams(t *testing.T) {
+	p := &e2e.Params{
+		Cipher:    e2e.Cipher,
+		ChunkSize: 1 << 20,
+	}
+
+	if err := p.Check(1 << 20); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := p.Check(1 << 21); !errors.Is(err, e2e.ErrInvalidParams) {
+		t.Fatal("Expected error for misaligned chunk size")
+	}
+
+	meta := map[string]*string{}
+	for k, v := range p.Metadata() {
+		meta[k] = &v
+	}
+
+	if q, err := e2e.ParamsFromMetadata(meta); err != nil || *q != *p {
+		t.Fatalf("Failed to parse metadata: %v", err)
+	}
+
+	if q, err := e2e.ParamsFromMetadata(map[string]*string{}); err != nil || q != nil {
+		t.Fatal("Expected no parameters for unencrypted objects")
+	}
+
+	key, _ := e2e.NewKey()
+	if dec, err := e2e.DecodeKey(e2e.EncodeKey(key)); err != nil || !bytes.Equal(dec, key) {
+		t.Fatal("Failed to decode key")
+	}
+}
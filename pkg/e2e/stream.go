@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package e2e
+
+import (
+	"errors"
+	"io"
+)
+
+var errClosed = errors.New("writer is closed")
+
+// Writer encrypts a plaintext stream.
+type Writer struct {
+	w      io.Writer
+	chunks *Chunks
+	params *Params
+
+	buf   []byte
+	out   []byte
+	index uint64
+	err   error
+}
+
+// NewWriter returns a writer which encrypts everything written to it and writes the ciphertext to w.
+// Close must be called to write the final chunk. It does not close w.
+func NewWriter(w io.Writer, key []byte, p *Params) (*Writer, error) {
+	c, err := NewChunks(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:      w,
+		chunks: c,
+		params: p,
+		buf:    make([]byte, 0, p.PlainChunkSize()),
+		out:    make([]byte, 0, p.ChunkSize),
+	}, nil
+}
+
+// Write encrypts b.
+// A full chunk is only written once more data follows as we do not know before if it is the final chunk.
+func (e *Writer) Write(b []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	n := 0
+	for len(b) > 0 {
+		if len(e.buf) == cap(e.buf) {
+			if e.err = e.flush(false); e.err != nil {
+				return n, e.err
+			}
+		}
+
+		k := min(len(b), cap(e.buf)-len(e.buf))
+		e.buf = append(e.buf, b[:k]...)
+		b = b[k:]
+		n += k
+	}
+
+	return n, nil
+}
+
+// Close writes the final chunk.
+func (e *Writer) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if e.err = e.flush(true); e.err != nil {
+		return e.err
+	}
+
+	e.err = errClosed
+
+	return nil
+}
+
+func (e *Writer) flush(final bool) error {
+	e.out = e.chunks.Seal(e.out[:0], e.buf, e.index, final)
+	if _, err := e.w.Write(e.out); err != nil {
+		return err
+	}
+
+	e.buf = e.buf[:0]
+	e.index++
+
+	return nil
+}
+
+// Reader decrypts a ciphertext stream.
+type Reader struct {
+	r      io.Reader
+	chunks *Chunks
+	params *Params
+
+	in    []byte
+	out   []byte
+	plain []byte
+	index uint64
+	final bool
+	err   error
+}
+
+// NewReader returns a reader which decrypts the ciphertext read from r.
+// It returns ErrAuthentication if the ciphertext has been modified or truncated.
+func NewReader(r io.Reader, key []byte, p *Params) (*Reader, error) {
+	c, err := NewChunks(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		r:      r,
+		chunks: c,
+		params: p,
+
+		// One additional byte tells us whether another chunk follows.
+		in:  make([]byte, 0, p.ChunkSize+1),
+		out: make([]byte, 0, p.PlainChunkSize()),
+	}, nil
+}
+
+// Read decrypts the next bytes into b.
+// Data of a chunk is only returned after the whole chunk has been authenticated.
+func (d *Reader) Read(b []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+
+		if d.final {
+			return 0, io.EOF
+		}
+
+		d.err = d.next()
+	}
+
+	n := copy(b, d.plain)
+	d.plain = d.plain[n:]
+
+	return n, nil
+}
+
+func (d *Reader) next() error {
+	n, err := io.ReadFull(d.r, d.in[len(d.in):cap(d.in)])
+	d.in = d.in[:len(d.in)+n]
+
+	var chunk []byte
+	switch {
+	case err == nil:
+		chunk = d.in[:d.params.ChunkSize]
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		chunk = d.in
+		d.final = true
+	default:
+		return err
+	}
+
+	if d.out, err = d.chunks.Open(d.out[:0], chunk, d.index, d.final); err != nil {
+		return err
+	}
+
+	d.plain = d.out
+	d.index++
+
+	// Keep the look-ahead byte for the next chunk.
+	if !d.final {
+		d.in = append(d.in[:0], d.in[d.params.ChunkSize])
+	}
+
+	return nil
+}
@@ -0,0 +1,477 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	_ "embed"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/abuse"
+	"github.com/stv0g/gose/pkg/audit"
+	"github.com/stv0g/gose/pkg/ban"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/store"
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+const (
+	// DefaultAdminPageSize is the default number of objects listed per page.
+	DefaultAdminPageSize = 100
+
+	// MaxAdminPageSize is the maximum number of objects listed per page.
+	MaxAdminPageSize = 1000
+
+	// DefaultAuditLimit is the default number of returned audit log events.
+	DefaultAuditLimit = 1000
+
+	// MaxAuditLimit is the maximum number of returned audit log events.
+	MaxAuditLimit = 10000
+)
+
+//go:embed admin.html
+var adminPage []byte
+
+type objectFilter struct {
+	Uploader   string
+	FileName   string
+	From       time.Time
+	To         time.Time
+	Expiration string
+}
+
+type adminObjectsResponse struct {
+	Objects []*server.Object `json:"objects"`
+
+	// Next is the token for retrieving the next page.
+	Next string `json:"next,omitempty"`
+}
+
+// HandleAdminPage serves a minimal HTML view of the admin API.
+func HandleAdminPage(c *gin.Context) {
+	c.Data(http.StatusOK, gin.MIMEHTML, adminPage)
+}
+
+// HandleAdminObjects lists the uploaded objects of a server.
+// Filters are applied per page. So pages might contain less objects than requested.
+func HandleAdminObjects(c *gin.Context) {
+	svr, ok := adminServer(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(DefaultAdminPageSize)), 10, 64)
+	if err != nil || limit <= 0 || limit > MaxAdminPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	var f objectFilter
+	if f, err = parseObjectFilter(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	keys, next, err := svr.ListObjectKeys(c.Request.Context(), c.Query("next"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list objects"})
+		return
+	}
+
+	resp := adminObjectsResponse{
+		Objects: []*server.Object{},
+		Next:    next,
+	}
+
+	for _, key := range keys {
+		// Skip GoSƐ's own state and foreign objects.
+		if !utils.IsValidETag(key) {
+			continue
+		}
+
+		// Objects might have been deleted since listing them, e.g. by lifecycle rules.
+		obj, err := svr.GetObjectInfo(c.Request.Context(), key)
+		if server.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			logging.FromContext(c).Error("Failed to get object", "key", key, "error", err)
+			continue
+		}
+
+		if f.match(obj) {
+			resp.Objects = append(resp.Objects, obj)
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// HandleAdminObject returns the meta-data and tags of an object.
+func HandleAdminObject(c *gin.Context) {
+	svr, ok := adminServer(c)
+	if !ok {
+		return
+	}
+
+	if !utils.IsValidETag(c.Param("etag")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+		return
+	}
+
+	obj, err := svr.GetObjectInfo(c.Request.Context(), c.Param("etag"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "failed to get object"})
+		return
+	}
+
+	c.JSON(http.StatusOK, obj)
+}
+
+// HandleAdminDeleteObject deletes an object.
+func HandleAdminDeleteObject(c *gin.Context) {
+	svr, ok := adminServer(c)
+	if !ok {
+		return
+	}
+
+	if !utils.IsValidETag(c.Param("etag")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+		return
+	}
+
+	if err := deleteObject(c, svr, c.Param("etag")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete object"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleAdminUploads lists the incomplete multi-part uploads of a server.
+func HandleAdminUploads(c *gin.Context) {
+	svr, ok := adminServer(c)
+	if !ok {
+		return
+	}
+
+	uploads, err := svr.ListUploads(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list uploads"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"uploads": uploads})
+}
+
+// HandleAdminAbortUpload aborts an incomplete multi-part upload.
+func HandleAdminAbortUpload(c *gin.Context) {
+	svr, ok := adminServer(c)
+	if !ok {
+		return
+	}
+
+	if !utils.IsValidETag(c.Param("etag")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+		return
+	}
+
+	if err := svr.AbortUpload(c.Request.Context(), c.Param("etag"), c.Param("upload_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to abort upload"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func adminServer(c *gin.Context) (server.Server, bool) {
+	svrs := c.MustGet("servers").(server.List)
+
+	svr, ok := svrs[c.Param("server")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invalid server"})
+	}
+
+	return svr, ok
+}
+
+func parseObjectFilter(c *gin.Context) (objectFilter, error) {
+	f := objectFilter{
+		Uploader:   c.Query("uploader"),
+		FileName:   strings.ToLower(c.Query("filename")),
+		Expiration: c.Query("expiration"),
+	}
+
+	var err error
+	if from := c.Query("from"); from != "" {
+		if f.From, err = parseTime(from); err != nil {
+			return f, err
+		}
+	}
+
+	if to := c.Query("to"); to != "" {
+		if f.To, err = parseTime(to); err != nil {
+			return f, err
+		}
+	}
+
+	return f, nil
+}
+
+// parseTime accepts either RFC3339 timestamps or plain dates.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+func (f *objectFilter) match(o *server.Object) bool {
+	if f.Uploader != "" && !matchUploader(f.Uploader, o.Metadata["Original-Uploader"]) {
+		return false
+	}
+
+	if f.FileName != "" && !strings.Contains(strings.ToLower(o.Metadata["Original-Filename"]), f.FileName) {
+		return false
+	}
+
+	if !f.From.IsZero() && o.LastModified.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && o.LastModified.After(f.To) {
+		return false
+	}
+
+	if f.Expiration != "" && o.Tags["expiration"] != f.Expiration {
+		return false
+	}
+
+	return true
+}
+
+// matchUploader matches an uploader IP against a single IP or a CIDR.
+func matchUploader(filter, uploader string) bool {
+	if _, n, err := net.ParseCIDR(filter); err == nil {
+		ip := net.ParseIP(uploader)
+		return ip != nil && n.Contains(ip)
+	}
+
+	return filter == uploader
+}
+
+type blockRequest struct {
+	Reason string `json:"reason"`
+
+	// Delete removes the object from all servers.
+	Delete bool `json:"delete"`
+}
+
+// HandleAdminReports lists the abuse reports, optionally filtered by ETag.
+func HandleAdminReports(c *gin.Context) {
+	abuses := c.MustGet("abuse").(*abuse.Manager)
+
+	reports, err := abuses.Reports(c.Query("etag"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+// HandleAdminDeleteReport dismisses an abuse report.
+func HandleAdminDeleteReport(c *gin.Context) {
+	abuses := c.MustGet("abuse").(*abuse.Manager)
+
+	if err := abuses.DeleteReport(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete report"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleAdminBlocklist lists the blocked ETags.
+func HandleAdminBlocklist(c *gin.Context) {
+	abuses := c.MustGet("abuse").(*abuse.Manager)
+
+	blocked, err := abuses.Blocklist()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get block list"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocklist": blocked})
+}
+
+// HandleAdminBlock adds an ETag to the block list.
+// Files encrypted with SSE-C have a random ETag per upload.
+// So blocking them does not prevent uploading the same content again.
+func HandleAdminBlock(c *gin.Context) {
+	abuses := c.MustGet("abuse").(*abuse.Manager)
+	svrs := c.MustGet("servers").(server.List)
+
+	etag := c.Param("etag")
+	if !utils.IsValidETag(etag) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+		return
+	}
+
+	var req blockRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "malformed request"})
+		return
+	}
+
+	b, err := abuses.Block(etag, req.Reason, c.GetString(gin.AuthUserKey))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to block etag"})
+		return
+	}
+
+	if req.Delete {
+		for _, svr := range svrs {
+			if err := deleteObject(c, svr, etag); err != nil {
+				logging.FromContext(c).Error("Failed to delete blocked object", "server", svr.Config.ID, "error", err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+// HandleAdminUnblock removes an ETag from the block list.
+func HandleAdminUnblock(c *gin.Context) {
+	abuses := c.MustGet("abuse").(*abuse.Manager)
+
+	if err := abuses.Unblock(c.Param("etag")); errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "etag is not blocked"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unblock etag"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type banRequest struct {
+	Subject string    `json:"subject"`
+	Reason  string    `json:"reason"`
+	Expires time.Time `json:"expires"`
+}
+
+// HandleAdminBans lists the active bans.
+func HandleAdminBans(c *gin.Context) {
+	bans := c.MustGet("bans").(*ban.List)
+
+	list, err := bans.Bans()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get bans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bans": list})
+}
+
+// HandleAdminBan bans an IP address, network or uploader identity.
+func HandleAdminBan(c *gin.Context) {
+	bans := c.MustGet("bans").(*ban.List)
+
+	var req banRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "malformed request"})
+		return
+	}
+
+	b, err := bans.Add(req.Subject, req.Reason, c.GetString(gin.AuthUserKey), req.Expires)
+	if errors.Is(err, ban.ErrInvalidSubject) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add ban"})
+		return
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+// HandleAdminUnban lifts the ban of the subject passed as query parameter.
+func HandleAdminUnban(c *gin.Context) {
+	bans := c.MustGet("bans").(*ban.List)
+
+	if err := bans.Remove(c.Query("subject")); errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subject is not banned"})
+		return
+	} else if errors.Is(err, ban.ErrConfigured) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove ban"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleAdminAudit queries the audit log.
+func HandleAdminAudit(c *gin.Context) {
+	auditLog := c.MustGet("audit").(*audit.Logger)
+	if auditLog == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "audit log is disabled"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(DefaultAuditLimit)))
+	if err != nil || limit <= 0 || limit > MaxAuditLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	f := &audit.Filter{
+		ETag:  c.Query("etag"),
+		Type:  c.Query("type"),
+		Limit: limit,
+	}
+
+	if from := c.Query("from"); from != "" {
+		if f.From, err = parseTime(from); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if to := c.Query("to"); to != "" {
+		if f.To, err = parseTime(to); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	events, err := auditLog.Query(f)
+	if errors.Is(err, audit.ErrNotQueryable) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// HandleAdminConfig returns the configuration with redacted secrets.
+func HandleAdminConfig(c *gin.Context) {
+	cfg := c.MustGet("config").(*config.Config)
+
+	c.JSON(http.StatusOK, cfg.Redacted())
+}
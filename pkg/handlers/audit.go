@@ -0,0 +1,25 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/audit"
+)
+
+// annotate adds details about the file to the audit log event of the request.
+func annotate(c *gin.Context, svr, etag, fileName string, size int64) {
+	e := audit.FromContext(c)
+	if e == nil {
+		return
+	}
+
+	e.Server = svr
+	e.ETag = etag
+	e.Size = size
+
+	if fileName != "" {
+		e.FileName = fileName
+	}
+}
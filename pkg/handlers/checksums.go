@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+// HandleChecksums returns the checksum of a completed upload.
+// The output is compatible with the sha256sum / sha512sum utilities.
+func HandleChecksums(c *gin.Context) {
+	svrs := c.MustGet("servers").(server.List)
+
+	etag := c.Param("etag")
+	svrName := c.Param("server")
+	algo := c.DefaultQuery("algo", server.ChecksumSHA256)
+
+	svr, ok := svrs[svrName]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invalid server"})
+		return
+	}
+
+	if !utils.IsValidETag(etag) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+		return
+	}
+
+	if _, err := server.NewChecksumHash(algo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checksum algorithm"})
+		return
+	}
+
+	obj, err := svr.HeadObjectWithContext(c.Request.Context(), &s3.HeadObjectInput{
+		Bucket: aws.String(svr.Config.Bucket),
+		Key:    aws.String(etag),
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "failed to get object"})
+		return
+	}
+
+	sums, err := svr.GetChecksums(c.Request.Context(), etag)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get checksums"})
+		return
+	}
+
+	sum, ok := sums[algo]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "checksum not available (yet)"})
+		return
+	}
+
+	fileName := etag
+	if fn, ok := obj.Metadata["Original-Filename"]; ok {
+		fileName = *fn
+	}
+
+	c.String(http.StatusOK, fmt.Sprintf("%s  %s\n", sum, fileName))
+}
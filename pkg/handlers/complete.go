@@ -4,18 +4,28 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/containrrr/shoutrrr/pkg/types"
 	"github.com/gin-gonic/gin"
 	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/e2e"
+	"github.com/stv0g/gose/pkg/logging"
 	"github.com/stv0g/gose/pkg/notifier"
+	"github.com/stv0g/gose/pkg/policy"
+	"github.com/stv0g/gose/pkg/quota"
+	"github.com/stv0g/gose/pkg/scanner"
 	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/session"
 	"github.com/stv0g/gose/pkg/utils"
 )
 
@@ -23,6 +33,7 @@ type completionRequest struct {
 	Server     string  `json:"server"`
 	ETag       string  `json:"etag"`
 	UploadID   string  `json:"upload_id"`
+	Session    string  `json:"session"`
 	Parts      []part  `json:"parts"`
 	NotifyMail *string `json:"notify_mail"`
 	Expiration *string `json:"expiration"`
@@ -31,12 +42,18 @@ type completionRequest struct {
 type completionResponse struct {
 	ETag string `json:"etag"`
 	URL  string `json:"url"`
+
+	// OwnerToken proves the ownership of the upload for later transfers.
+	OwnerToken string `json:"owner_token,omitempty"`
 }
 
 // HandleComplete handles a completed upload.
 func HandleComplete(c *gin.Context) {
 	svrs := c.MustGet("servers").(server.List)
 	cfg := c.MustGet("config").(*config.Config)
+	quotas := c.MustGet("quota").(*quota.Manager)
+	scan := c.MustGet("scanner").(*scanner.Scanner)
+	signer := c.MustGet("sessions").(*session.Signer)
 
 	var req completionRequest
 	if err := c.BindJSON(&req); err != nil {
@@ -55,6 +72,13 @@ func HandleComplete(c *gin.Context) {
 		return
 	}
 
+	logging.With(c, "etag", req.ETag)
+
+	sess := checkSession(c, req.Session, req.Server, req.ETag, req.UploadID)
+	if sess == nil {
+		return
+	}
+
 	// Ceph's RadosGW does not yet support tagging during the initiation of multi-part uploads.
 	// So we tag here with a separate request instead of the MPU initiate req.
 	//  See: https://github.com/ceph/ceph/pull/38275
@@ -70,57 +94,164 @@ func HandleComplete(c *gin.Context) {
 		}
 	}
 
-	if len(req.Parts) > int(svr.Config.MaxUploadSize/svr.Config.PartSize) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "max upload size exceeded"})
+	// We do not trust the parts list of the client and check the actually uploaded parts instead.
+	uploadedParts, err := svr.ListAllParts(c.Request.Context(), req.ETag, req.UploadID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get parts"})
 		return
 	}
 
+	if err := svr.CheckPartLayout(uploadedParts); err != nil {
+		releaseQuota(c, req.Server, req.ETag)
+		abortUpload(c, svr, req.ETag, req.UploadID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(uploadedParts) != len(req.Parts) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parts mismatch"})
+		return
+	}
+
+	var size int64
+	for _, p := range uploadedParts {
+		size += *p.Size
+	}
+
+	annotate(c, req.Server, req.ETag, "", size)
+
+	var expires time.Time
+	if exp != nil {
+		expires = time.Now().AddDate(0, 0, int(exp.Days))
+	}
+
 	// Prepare MPU completion request.
 	parts := []*s3.CompletedPart{}
-	for _, part := range req.Parts {
+	partETags := []string{}
+	for _, part := range uploadedParts {
 		parts = append(parts, &s3.CompletedPart{
-			PartNumber: aws.Int64(part.Number),
-			ETag:       aws.String(part.ETag),
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
 		})
+		partETags = append(partETags, *part.ETag)
+	}
+
+	// Check if the parts add up to the object key.
+	// Encrypted parts have no MD5 ETags. But S3 has already checked their Content-MD5 headers.
+	if svr.ETagIsMD5() {
+		if etag, err := utils.MultipartETag(partETags); err != nil || etag != req.ETag {
+			releaseQuota(c, req.Server, req.ETag)
+			abortUpload(c, svr, req.ETag, req.UploadID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "checksum mismatch"})
+			return
+		}
 	}
 
-	respCompleteMPU, err := svr.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+	completeMPU := &s3.CompleteMultipartUploadInput{
 		Bucket:   aws.String(svr.Config.Bucket),
 		Key:      aws.String(req.ETag),
 		UploadId: aws.String(req.UploadID),
 		MultipartUpload: &s3.CompletedMultipartUpload{
 			Parts: parts,
 		},
-	})
+	}
+
+	completeMPU.SSECustomerAlgorithm, completeMPU.SSECustomerKey = server.CustomerKey(sess.Key)
+
+	// The upload can be completed again after a failure. So we keep its reservation.
+	respCompleteMPU, err := svr.CompleteMultipartUploadWithContext(c.Request.Context(), completeMPU)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Tag object with expiration tag here
-	if exp != nil {
-		if _, err := svr.PutObjectTagging(&s3.PutObjectTaggingInput{
+	if etag := strings.Trim(*respCompleteMPU.ETag, "\""); svr.ETagIsMD5() && etag != req.ETag {
+		if _, err := svr.DeleteObjectWithContext(c.Request.Context(), &s3.DeleteObjectInput{
 			Bucket: aws.String(svr.Config.Bucket),
 			Key:    aws.String(req.ETag),
-			Tagging: &s3.Tagging{
-				TagSet: []*s3.Tag{
-					{
-						Key:   aws.String("expiration"),
-						Value: aws.String(exp.ID),
-					},
-				},
-			},
 		}); err != nil {
+			logging.FromContext(c).Error("Failed to delete corrupted object", "error", err)
+		}
+
+		releaseQuota(c, req.Server, req.ETag)
+
+		c.JSON(http.StatusBadRequest, gin.H{"error": "final checksum mismatch"})
+		return
+	}
+
+	// The size is only committed once the object exists. So failed uploads never count.
+	if quotas != nil {
+		// Uploads are only discarded if they exceed the quota.
+		// Other errors of the store must not destroy the uploaded object.
+		if err := quotas.Commit(Identity(c), quota.Key(req.Server, req.ETag), size, expires); errors.Is(err, quota.ErrExceeded) {
+			if err := svr.DeleteObjectByKey(c.Request.Context(), req.ETag); err != nil {
+				logging.FromContext(c).Error("Failed to delete object exceeding quota", "error", err)
+			}
+
+			releaseQuota(c, req.Server, req.ETag)
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			logging.FromContext(c).Error("Failed to commit quota", "error", err)
+		}
+	}
+
+	// Tag object with expiration tag here
+	tags := map[string]string{}
+	if exp != nil {
+		tags["expiration"] = exp.ID
+	}
+
+	// Block downloads until the scan has passed.
+	if scan != nil {
+		tags[scanner.TagKey] = scanner.StatusPending
+	}
+
+	if len(tags) > 0 {
+		if err := svr.SetTags(c.Request.Context(), req.ETag, tags); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to tag object"})
 			return
 		}
 	}
 
+	// Detect disguised files based on their actual content.
+	if svr.Config.Policy.Sniff {
+		if sniffErr := sniffObject(c.Request.Context(), svr, req.ETag); errors.Is(sniffErr, policy.ErrForbidden) {
+			if obj, err := svr.HeadObjectWithContext(c.Request.Context(), &s3.HeadObjectInput{
+				Bucket: aws.String(svr.Config.Bucket),
+				Key:    aws.String(req.ETag),
+			}); err == nil {
+				go notifyAdmins(context.WithoutCancel(c.Request.Context()), cfg, "", obj, nil, "Rejected upload: "+sniffErr.Error())
+			}
+
+			if _, err := svr.DeleteObjectWithContext(c.Request.Context(), &s3.DeleteObjectInput{
+				Bucket: aws.String(svr.Config.Bucket),
+				Key:    aws.String(req.ETag),
+			}); err != nil {
+				logging.FromContext(c).Error("Failed to delete rejected object", "error", err)
+			}
+
+			forgetQuota(c, Identity(c), req.Server, req.ETag)
+
+			c.JSON(http.StatusForbidden, gin.H{"error": sniffErr.Error()})
+			return
+		} else if sniffErr != nil {
+			// The error might be transient. So we keep the already tagged object.
+			logging.FromContext(c).Error("Failed to sniff object", "error", sniffErr)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check file type"})
+			return
+		}
+	}
+
 	// Retrieve meta-data.
-	obj, err := svr.HeadObject(&s3.HeadObjectInput{
+	headObj := &s3.HeadObjectInput{
 		Bucket: aws.String(svr.Config.Bucket),
 		Key:    aws.String(req.ETag),
-	})
+	}
+
+	headObj.SSECustomerAlgorithm, headObj.SSECustomerKey = server.CustomerKey(sess.Key)
+
+	obj, err := svr.HeadObjectWithContext(c.Request.Context(), headObj)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get object"})
 		return
@@ -133,36 +264,132 @@ func HandleComplete(c *gin.Context) {
 		url = svr.GetObjectURL(req.ETag).String()
 	}
 
-	// Send notifications.
-	go func(key string) {
-		if cfg.Notification != nil && cfg.Notification.Uploads {
-			if notif, err := notifier.NewNotifier(cfg.Notification.Template, cfg.Notification.URLs...); err != nil {
-				log.Fatalf("Failed to create notification sender: %s", err)
-			} else {
-				if err := notif.Notify(url, obj, types.Params{
-					"Title": "New upload",
-				}); err != nil {
-					fmt.Printf("Failed to send notification: %s", err)
+	// Scan for malware, send notifications and calculate checksums.
+	// The request context is canceled once we responded. So we keep only its values.
+	identity := Identity(c)
+	go func(ctx context.Context, logger *slog.Logger, key string) {
+		if scan != nil {
+			status, res, err := scan.ScanObject(ctx, svr, key)
+			if err != nil {
+				logger.Error("Failed to scan object", "error", err)
+				notifyAdmins(ctx, cfg, url, obj, nil, "Scan failed")
+			} else if status == scanner.StatusInfected {
+				logger.Warn("Found malware", "signature", res.Signature)
+				notifyAdmins(ctx, cfg, url, obj, nil, "Infected upload: "+res.Signature)
+
+				if cfg.Scanner.Action == scanner.ActionDelete && quotas != nil {
+					if err := quotas.Forget(identity, quota.Key(req.Server, key)); err != nil {
+						logger.Error("Failed to update quota of deleted object", "error", err)
+					}
 				}
+
+				return
 			}
 		}
 
-		if cfg.Notification.Mail != nil && req.NotifyMail != nil {
-			u := fmt.Sprintf("%s&ToAddresses=%s", cfg.Notification.Mail.URL, *req.NotifyMail)
-			if notif, err := notifier.NewNotifier(cfg.Notification.Mail.Template, u); err != nil {
-				log.Fatalf("Failed to create notification sender: %s", err)
-			} else {
-				if err := notif.Notify(url, obj, types.Params{
-					"Title": "New upload",
-				}); err != nil {
-					fmt.Printf("Failed to send notification: %s", err)
+		notify := func(title string, sums map[string]string) {
+			if cfg.Notification != nil && cfg.Notification.Uploads {
+				if notif, err := notifier.NewNotifier(cfg.Notification.Template, cfg.Notification.URLs...); err != nil {
+					logger.Error("Failed to create notification sender", "error", err)
+				} else {
+					if err := notif.Notify(ctx, url, obj, sums, types.Params{
+						"Title": title,
+					}); err != nil {
+						logger.Error("Failed to send notification", "error", err)
+					}
+				}
+			}
+
+			if cfg.Notification.Mail != nil && req.NotifyMail != nil {
+				u := fmt.Sprintf("%s&ToAddresses=%s", cfg.Notification.Mail.URL, *req.NotifyMail)
+				if notif, err := notifier.NewNotifier(cfg.Notification.Mail.Template, u); err != nil {
+					logger.Error("Failed to create notification sender", "error", err)
+				} else {
+					if err := notif.Notify(ctx, url, obj, sums, types.Params{
+						"Title": title,
+					}); err != nil {
+						logger.Error("Failed to send notification", "error", err)
+					}
 				}
 			}
 		}
-	}(req.ETag)
 
+		// Checksums of large files take long to calculate.
+		// So they are sent in a follow-up notification.
+		notify("New upload", nil)
+
+		// We do not keep the keys of objects encrypted with SSE-C for reading them later.
+		// Checksums of the ciphertext of end-to-end encrypted files are of no use for their recipients.
+		params, _ := e2e.ParamsFromMetadata(obj.Metadata)
+
+		if len(svr.Config.Checksums) > 0 && !svr.UsesCustomerKeys() && params == nil {
+			if sums, err := svr.ComputeChecksums(ctx, key, svr.Config.Checksums); err != nil {
+				logger.Error("Failed to calculate checksums", "error", err)
+			} else {
+				notify("Checksums of new upload", sums)
+			}
+		}
+
+		// Replicas receive the tags with the checksums and the scan result.
+		// Infected files are never replicated.
+		for _, id := range svr.Config.Replicas {
+			if err := svr.CopyTo(ctx, svrs[id], key, nil); err != nil {
+				logger.Error("Failed to replicate object", "replica", id, "error", err)
+			} else {
+				logger.Info("Replicated object", "replica", id)
+			}
+		}
+	}(context.WithoutCancel(c.Request.Context()), logging.FromContext(c), req.ETag)
+
+	// Only the uploader receives the link including the key.
+	// Notifications above are sent without it.
+	// Clients add the keys of end-to-end encrypted files themselves.
+	respURL := url
+	if sess.Key != nil {
+		respURL += "#key=" + server.EncodeCustomerKey(sess.Key)
+	}
+
+	// A missing owner token only prevents later transfers.
+	owner, err := signer.IssueOwner(req.Server, req.ETag, expires)
+	if err != nil {
+		logging.FromContext(c).Error("Failed to issue owner token", "error", err)
+	}
+
+	// The ETag of the completed object has been checked above unless it is encrypted.
 	c.JSON(200, &completionResponse{
-		URL:  url,
-		ETag: strings.Trim(*respCompleteMPU.ETag, "\""),
+		URL:        respURL,
+		ETag:       req.ETag,
+		OwnerToken: owner,
+	})
+}
+
+func abortUpload(c *gin.Context, svr server.Server, key, uploadID string) {
+	if err := svr.AbortUpload(c.Request.Context(), key, uploadID); err != nil {
+		logging.FromContext(c).Error("Failed to abort upload", "upload_id", uploadID, "error", err)
+	}
+}
+
+// sniffObject checks the content type detected from the first bytes of an object against the policy.
+func sniffObject(ctx context.Context, svr server.Server, key string) error {
+	obj, err := svr.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(svr.Config.Bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", policy.SniffLength-1)),
 	})
+	if err != nil {
+		return fmt.Errorf("failed to get object: %w", err)
+	}
+	defer obj.Body.Close()
+
+	// The content of end-to-end encrypted files is not accessible to us.
+	if params, _ := e2e.ParamsFromMetadata(obj.Metadata); params != nil {
+		return nil
+	}
+
+	head, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read object: %w", err)
+	}
+
+	return policy.CheckType(&svr.Config.Policy, policy.Sniff(head))
 }
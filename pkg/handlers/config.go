@@ -6,6 +6,7 @@ package handlers
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/health"
 	"github.com/stv0g/gose/pkg/server"
 )
 
@@ -21,10 +22,20 @@ type respBuild struct {
 	Commit  string `json:"commit"`
 }
 
+type serverResponse struct {
+	config.S3ServerConfig
+
+	// Status is the result of the last health check of the server.
+	Status string `json:"status"`
+
+	// Encryption is the server-side encryption mode without any key IDs.
+	Encryption string `json:"encryption,omitempty"`
+}
+
 type configResponse struct {
-	Build    respBuild               `json:"build"`
-	Servers  []config.S3ServerConfig `json:"servers"`
-	Features featureResponse         `json:"features"`
+	Build    respBuild        `json:"build"`
+	Servers  []serverResponse `json:"servers"`
+	Features featureResponse  `json:"features"`
 }
 
 // HandleConfigWith returns runtime configuration to the frontend.
@@ -32,10 +43,17 @@ func HandleConfigWith(version, commit, date string) func(*gin.Context) {
 	return func(c *gin.Context) {
 		cfg := c.MustGet("config").(*config.Config)
 		svrs := c.MustGet("servers").(server.List)
+		checker := c.MustGet("health").(*health.Checker)
 
-		svrsResp := []config.S3ServerConfig{}
-		for _, svr := range svrs {
-			svrsResp = append(svrsResp, svr.Config.S3ServerConfig)
+		// Keep the order of the configuration as the frontend preselects the first healthy server.
+		svrsResp := []serverResponse{}
+		for _, sc := range cfg.Servers {
+			svr := svrs[sc.ID]
+			svrsResp = append(svrsResp, serverResponse{
+				S3ServerConfig: svr.Config.S3ServerConfig,
+				Status:         checker.Status(svr.Component()),
+				Encryption:     svr.EncryptionMode(),
+			})
 		}
 
 		c.JSON(200, &configResponse{
@@ -4,23 +4,84 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	_ "embed"
 	"fmt"
-	"log"
+	"html/template"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/containrrr/shoutrrr/pkg/types"
+	units "github.com/docker/go-units"
 	"github.com/gin-gonic/gin"
 	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/e2e"
+	"github.com/stv0g/gose/pkg/logging"
 	"github.com/stv0g/gose/pkg/notifier"
+	"github.com/stv0g/gose/pkg/scanner"
 	"github.com/stv0g/gose/pkg/server"
 	"github.com/stv0g/gose/pkg/utils"
 	"github.com/vfaronov/httpheader"
 )
 
+const scanPendingPage = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta http-equiv="refresh" content="10">
+	<title>GoSƐ - Scan pending</title>
+</head>
+<body>
+	<h1>Scan pending</h1>
+	<p>This file is currently being scanned for malware. The download starts automatically once the scan has passed.</p>
+</body>
+</html>
+`
+
+//go:embed download.html
+var downloadPage []byte
+
+//go:embed decrypt.html
+var decryptPageTemplate string
+
+var decryptPage = template.Must(template.New("decrypt").Parse(decryptPageTemplate))
+
+//go:embed file.html
+var filePageTemplate string
+
+var filePage = template.Must(template.New("file").Parse(filePageTemplate))
+
+type filePageArgs struct {
+	FileName  string
+	Size      string
+	Checksums map[string]string
+
+	// URL downloads the file without the landing page.
+	URL string
+}
+
+type downloadKeyRequest struct {
+	Key string `json:"key"`
+}
+
+type downloadKeyResponse struct {
+	URL string `json:"url"`
+
+	// Headers which must be sent by the client along with the download.
+	Headers map[string]string `json:"headers"`
+}
+
 // HandleDownload handles a request for downloading a file.
+// Files encrypted with customer keys are downloaded by a landing page which passes the key from the link to HandleDownloadKey.
+// End-to-end encrypted files are decrypted by a landing page in the browser.
+// Their ciphertext is downloaded by passing the "raw" query parameter.
+// Browsers get a landing page showing the checksums of other files which starts the download with the "raw" parameter.
+// Other clients like curl are redirected to the file directly.
 func HandleDownload(c *gin.Context) {
 	var err error
 
@@ -42,16 +103,115 @@ func HandleDownload(c *gin.Context) {
 		return
 	}
 
+	if !checkBlocked(c, etag) {
+		return
+	}
+
+	// The key is only known to the browser of the downloader.
+	if svr.UsesCustomerKeys() {
+		c.Data(http.StatusOK, gin.MIMEHTML, downloadPage)
+		return
+	}
+
 	// Retrieve meta-data.
-	obj, err := svr.HeadObject(&s3.HeadObjectInput{
+	obj, err := svr.HeadObjectWithContext(c.Request.Context(), &s3.HeadObjectInput{
 		Bucket: aws.String(svr.Config.Bucket),
 		Key:    aws.String(etag),
 	})
+	if err != nil {
+		// Serve the download from a replica if the object is not accessible on the primary server.
+		for _, id := range svr.Config.Replicas {
+			replica := svrs[id]
+			if obj, err = replica.HeadObjectWithContext(c.Request.Context(), &s3.HeadObjectInput{
+				Bucket: aws.String(replica.Config.Bucket),
+				Key:    aws.String(etag),
+			}); err == nil {
+				logging.FromContext(c).Warn("Falling back to replica", "replica", id)
+				svr = replica
+				break
+			}
+		}
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get object"})
 		return
 	}
 
+	annotate(c, svr.Config.ID, etag, fileName, aws.Int64Value(obj.ContentLength))
+
+	// Block downloads of files which have not passed the malware scan.
+	if scan := c.MustGet("scanner").(*scanner.Scanner); scan != nil {
+		tags, err := svr.GetTags(c.Request.Context(), etag)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get object tags"})
+			return
+		}
+
+		if status := tags[scanner.TagKey]; !scanner.DownloadAllowed(status) {
+			switch status {
+			case scanner.StatusPending:
+				c.Header("Retry-After", "10")
+				if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEHTML {
+					c.Data(http.StatusServiceUnavailable, gin.MIMEHTML, []byte(scanPendingPage))
+				} else {
+					c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scan pending"})
+				}
+
+			case scanner.StatusInfected:
+				c.JSON(http.StatusForbidden, gin.H{"error": "file is infected"})
+
+			default:
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "file could not be scanned"})
+			}
+
+			return
+		}
+	}
+
+	params, err := e2e.ParamsFromMetadata(obj.Metadata)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params != nil && c.Query("raw") == "" {
+		var page bytes.Buffer
+		if err := decryptPage.Execute(&page, params); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render page"})
+			return
+		}
+
+		c.Data(http.StatusOK, gin.MIMEHTML, page.Bytes())
+		return
+	}
+
+	if len(svr.Config.Checksums) > 0 && c.Query("raw") == "" && strings.Contains(c.GetHeader("Accept"), gin.MIMEHTML) {
+		// Checksums might not have been calculated yet.
+		sums, err := svr.GetChecksums(c.Request.Context(), etag)
+		if err != nil {
+			logging.FromContext(c).Warn("Failed to get checksums", "error", err)
+		}
+
+		u := *c.Request.URL
+		q := u.Query()
+		q.Set("raw", "1")
+		u.RawQuery = q.Encode()
+
+		var page bytes.Buffer
+		if err := filePage.Execute(&page, &filePageArgs{
+			FileName:  fileName,
+			Size:      units.HumanSize(float64(aws.Int64Value(obj.ContentLength))),
+			Checksums: sums,
+			URL:       u.RequestURI(),
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render page"})
+			return
+		}
+
+		c.Data(http.StatusOK, gin.MIMEHTML, page.Bytes())
+		return
+	}
+
 	// RFC8187
 	contentDisposition := "attachment; filename*=" + httpheader.EncodeExtValue(fileName, "")
 
@@ -68,6 +228,98 @@ func HandleDownload(c *gin.Context) {
 		return
 	}
 
+	notifyDownload(c, cfg, svr, etag, obj)
+
+	c.Redirect(http.StatusTemporaryRedirect, signedURL)
+}
+
+// HandleDownloadKey presigns the download of a file encrypted with a customer key.
+// The key is sent by the landing page served by HandleDownload.
+func HandleDownloadKey(c *gin.Context) {
+	svrs := c.MustGet("servers").(server.List)
+	cfg := c.MustGet("config").(*config.Config)
+
+	etag := c.Param("etag")
+	fileName := c.Param("filename")
+	svrName := c.Param("server")
+
+	svr, ok := svrs[svrName]
+	if !ok || !svr.UsesCustomerKeys() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invalid server"})
+		return
+	}
+
+	if !utils.IsValidETag(etag) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+		return
+	}
+
+	if !checkBlocked(c, etag) {
+		return
+	}
+
+	var req downloadKeyRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "malformed request"})
+		return
+	}
+
+	key, err := server.DecodeCustomerKey(req.Key)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	algo, encKey := server.CustomerKey(key)
+
+	// S3 rejects requests with a wrong key.
+	obj, err := svr.HeadObjectWithContext(c.Request.Context(), &s3.HeadObjectInput{
+		Bucket:               aws.String(svr.Config.Bucket),
+		Key:                  aws.String(etag),
+		SSECustomerAlgorithm: algo,
+		SSECustomerKey:       encKey,
+	})
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "failed to get object"})
+		return
+	}
+
+	annotate(c, svr.Config.ID, etag, fileName, aws.Int64Value(obj.ContentLength))
+
+	// RFC8187
+	contentDisposition := "attachment; filename*=" + httpheader.EncodeExtValue(fileName, "")
+
+	getReq, _ := svr.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     aws.String(svr.Config.Bucket),
+		Key:                        aws.String(etag),
+		ResponseContentDisposition: aws.String(contentDisposition),
+		ResponseContentType:        obj.ContentType,
+		SSECustomerAlgorithm:       algo,
+		SSECustomerKey:             encKey,
+	})
+
+	signedURL, hdr, err := getReq.PresignRequest(10 * time.Second)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to presign request: %s", err)})
+		return
+	}
+
+	notifyDownload(c, cfg, svr, etag, obj)
+
+	c.JSON(http.StatusOK, &downloadKeyResponse{
+		URL:     signedURL,
+		Headers: signedHeaders(hdr),
+	})
+}
+
+// notifyDownload sends the download notifications in the background.
+// The links in the notifications never include the keys of encrypted files.
+// GoSƐ does not even know the keys of end-to-end encrypted files.
+func notifyDownload(c *gin.Context, cfg *config.Config, svr server.Server, etag string, obj *s3.HeadObjectOutput) {
+	if cfg.Notification == nil || !cfg.Notification.Downloads {
+		return
+	}
+
 	var shortURL string
 	if u, ok := obj.Metadata["Original-Short-Url"]; ok {
 		shortURL = *u
@@ -75,19 +327,16 @@ func HandleDownload(c *gin.Context) {
 		shortURL = svr.GetObjectURL(etag).String()
 	}
 
-	go func(svr server.Server, key string) {
-		if cfg.Notification != nil && cfg.Notification.Downloads {
-			if notif, err := notifier.NewNotifier(cfg.Notification.Template, cfg.Notification.URLs...); err != nil {
-				log.Fatalf("Failed to create notification sender: %s", err)
-			} else {
-				if err := notif.Notify(shortURL, obj, types.Params{
-					"Title": "New download",
-				}); err != nil {
-					fmt.Printf("Failed to send notification: %s", err)
-				}
+	go func(ctx context.Context, logger *slog.Logger) {
+		if notif, err := notifier.NewNotifier(cfg.Notification.Template, cfg.Notification.URLs...); err != nil {
+			logger.Error("Failed to create notification sender", "error", err)
+		} else {
+			sums, _ := svr.GetChecksums(ctx, etag)
+			if err := notif.Notify(ctx, shortURL, obj, sums, types.Params{
+				"Title": "New download",
+			}); err != nil {
+				logger.Error("Failed to send notification", "error", err)
 			}
 		}
-	}(svr, etag)
-
-	c.Redirect(http.StatusTemporaryRedirect, signedURL)
+	}(context.WithoutCancel(c.Request.Context()), logging.FromContext(c))
 }
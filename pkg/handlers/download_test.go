@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/abuse"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/handlers"
+	"github.com/stv0g/gose/pkg/scanner"
+	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/store"
+)
+
+func TestDownloadChecksums(t *testing.T) {
+	const (
+		key = "d41d8cd98f00b204e9800998ecf8427e-1"
+		sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	)
+
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != "/bucket/"+key:
+			w.WriteHeader(http.StatusNotFound)
+
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", "5")
+			w.Header().Set("Content-Type", "text/plain")
+
+		case r.URL.Query().Has("tagging"):
+			io.WriteString(w, `<Tagging><TagSet><Tag><Key>`+server.ChecksumTagPrefix+`sha256</Key><Value>`+sum+`</Value></Tag></TagSet></Tagging>`)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s3.Close()
+
+	svrs := server.NewList([]config.S3Server{
+		{
+			S3ServerConfig: config.S3ServerConfig{
+				ID:       "s1",
+				PartSize: config.MinPartSize,
+			},
+			Checksums: []string{server.ChecksumSHA256},
+			Endpoint:  strings.TrimPrefix(s3.URL, "http://"),
+			Bucket:    "bucket",
+			Region:    "us-east-1",
+			PathStyle: true,
+			NoSSL:     true,
+			AccessKey: "access",
+			SecretKey: "secret",
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/download/:server/:etag/:filename", func(c *gin.Context) {
+		c.Set("servers", svrs)
+		c.Set("config", &config.Config{})
+		c.Set("abuse", abuse.NewManager(store.NewMemory(), time.Minute))
+		c.Set("scanner", (*scanner.Scanner)(nil))
+	}, handlers.HandleDownload)
+
+	// Browsers get a landing page.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/download/s1/"+key+"/file.txt", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	router.ServeHTTP(w, r)
+
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, sum) || !strings.Contains(body, "file.txt?raw=1") {
+		t.Fatalf("Unexpected landing page %d: %s", w.Code, body)
+	}
+
+	// Other clients and the landing page itself get the file.
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/download/s1/"+key+"/file.txt", nil),
+		httptest.NewRequest(http.MethodGet, "/download/s1/"+key+"/file.txt?raw=1", nil),
+	} {
+		r.Header.Set("Accept", "*/*")
+		if r.URL.RawQuery != "" {
+			r.Header.Set("Accept", "text/html")
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusTemporaryRedirect || !strings.Contains(w.Header().Get("Location"), "/bucket/"+key) {
+			t.Fatalf("Unexpected response %d for %s: %s", w.Code, r.URL, w.Body)
+		}
+	}
+}
@@ -7,20 +7,28 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/health"
 )
 
-// HandleHealthz is Gose's health check handler
-func HandleHealthz(c *gin.Context) {
-	svrs := c.MustGet("servers").(server.List)
+// HandleLivez reports that GoSƐ is running and able to handle requests.
+// It does not depend on any other service, so an unreachable backend does not cause restarts.
+func HandleLivez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": health.StatusUp,
+	})
+}
 
-	// TODO: check health status of notifier?
-	// TODO: check health status of shortener?
-	// shortener := c.MustGet("shortener").(*shortener.Shortener)
+// HandleReadyzWith reports the cached status of all components.
+// It responds with 503 if a critical component is not healthy.
+func HandleReadyzWith(checker *health.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := checker.Report()
 
-	for _, svr := range svrs {
-		if !svr.Healthy() {
-			c.AbortWithStatus(http.StatusInternalServerError)
+		status := http.StatusOK
+		if !r.Ready() {
+			status = http.StatusServiceUnavailable
 		}
+
+		c.JSON(status, r)
 	}
 }
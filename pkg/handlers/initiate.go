@@ -4,6 +4,8 @@
 package handlers
 
 import (
+	"context"
+	"maps"
 	"mime"
 	"net/http"
 	"net/url"
@@ -14,7 +16,13 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gin-gonic/gin"
 	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/e2e"
+	"github.com/stv0g/gose/pkg/health"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/policy"
+	"github.com/stv0g/gose/pkg/quota"
 	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/session"
 	"github.com/stv0g/gose/pkg/shortener"
 	"github.com/stv0g/gose/pkg/utils"
 )
@@ -25,15 +33,27 @@ const (
 )
 
 type initiateRequest struct {
+	// Server is the ID of a server or "auto" to choose a healthy server of Group.
 	Server   string `json:"server"`
+	Group    string `json:"group,omitempty"`
 	ETag     string `json:"etag"`
 	FileName string `json:"filename"`
 	ShortURL bool   `json:"short_url"`
 	Type     string `json:"type"`
+	Size     int64  `json:"size"`
+
+	// Session is an optional token of a previous initiate request used for resuming an upload.
+	Session string `json:"session,omitempty"`
+
+	// Encryption describes the end-to-end encryption of the uploaded file.
+	// The ETag and size are those of the ciphertext.
+	Encryption *e2e.Params `json:"encryption,omitempty"`
 }
 
 type initiateResponse struct {
-	ETag string `json:"etag"`
+	// Server is the ID of the server which must be used for subsequent requests.
+	Server string `json:"server"`
+	ETag   string `json:"etag"`
 
 	// We do not have a URL for resumed uploads due to limitations of the S3 API.
 	URL string `json:"url,omitempty"`
@@ -41,6 +61,9 @@ type initiateResponse struct {
 	// An empty UploadID indicate that the file already existed.
 	UploadID string `json:"upload_id,omitempty"`
 
+	// Session is a signed token which must be passed to subsequent part and complete requests.
+	Session string `json:"session,omitempty"`
+
 	Parts []part `json:"parts"`
 }
 
@@ -51,6 +74,8 @@ func HandleInitiate(c *gin.Context) {
 	svrs := c.MustGet("servers").(server.List)
 	shortener := c.MustGet("shortener").(*shortener.Shortener)
 	cfg := c.MustGet("config").(*config.Config)
+	signer := c.MustGet("sessions").(*session.Signer)
+	quotas := c.MustGet("quota").(*quota.Manager)
 
 	var req initiateRequest
 	if err := c.BindJSON(&req); err != nil {
@@ -58,6 +83,15 @@ func HandleInitiate(c *gin.Context) {
 		return
 	}
 
+	if req.Server == config.AutoServer {
+		if req.Server, err = selectServer(c, &req); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	annotate(c, req.Server, req.ETag, req.FileName, req.Size)
+
 	svr, ok := svrs[req.Server]
 	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{"error": "invalid server"})
@@ -82,13 +116,64 @@ func HandleInitiate(c *gin.Context) {
 		return
 	}
 
+	logging.With(c, "etag", req.ETag)
+
+	if !checkBlocked(c, req.ETag) {
+		return
+	}
+
+	if err := policy.Check(&svr.Config.Policy, req.Type, req.FileName); err != nil {
+		go notifyAdmins(context.WithoutCancel(c.Request.Context()), cfg, "", pseudoObject(req.FileName, req.Type, c.ClientIP(), req.Size), nil, "Rejected upload: "+err.Error())
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Size < 0 || req.Size > int64(svr.Config.MaxUploadSize) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid size"})
+		return
+	}
+
+	if req.Encryption != nil {
+		if svr.UsesCustomerKeys() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end-to-end encryption is not supported by this server"})
+			return
+		}
+
+		// Each chunk must be uploaded as a single part.
+		if err := req.Encryption.Check(int64(svr.Config.PartSize)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	resp := initiateResponse{
-		ETag:  req.ETag,
-		Parts: []part{},
+		Server: req.Server,
+		ETag:   req.ETag,
+		Parts:  []part{},
+	}
+
+	// Objects encrypted with customer keys can not be shared between uploads.
+	// So each upload gets its own random object key and encryption key.
+	var key []byte
+	if svr.UsesCustomerKeys() {
+		// Sessions of other files must not resume their object and key.
+		if sess, err := signer.Verify(req.Session); err == nil && sess.Server == req.Server && sess.Content == req.ETag && sess.Identity == Identity(c) && sess.Key != nil {
+			resp.ETag, key = sess.ETag, sess.Key
+		} else {
+			if resp.ETag, err = utils.RandomETag(req.ETag); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate object key"})
+				return
+			}
+
+			if key, err = server.NewCustomerKey(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate encryption key"})
+				return
+			}
+		}
 	}
 
 	// Check if an object with this key already exists.
-	respObj, err := svr.HeadObject(&s3.HeadObjectInput{
+	respObj, err := svr.HeadObjectWithContext(c.Request.Context(), &s3.HeadObjectInput{
 		Bucket: aws.String(svr.Config.Bucket),
 		Key:    aws.String(resp.ETag),
 	})
@@ -96,6 +181,15 @@ func HandleInitiate(c *gin.Context) {
 	u, _ := url.Parse(cfg.BaseURL)
 	u.Path += filepath.Join("api/v1/download", req.Server, resp.ETag, req.FileName)
 
+	// End-to-end encrypted files are never de-duplicated as we can not check that the uploader has the key.
+	// Neither must they replace existing objects or be passed off as plaintext ones with the same ETag.
+	if err == nil {
+		if params, _ := e2e.ParamsFromMetadata(respObj.Metadata); req.Encryption != nil || params != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "a different file with the same ETag already exists"})
+			return
+		}
+	}
+
 	// Object already exists.
 	if err == nil {
 		if req.ShortURL {
@@ -111,7 +205,7 @@ func HandleInitiate(c *gin.Context) {
 					return
 				}
 
-				if u, err = shortener.Shorten(u); err != nil {
+				if u, err = shortener.Shorten(c.Request.Context(), u); err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 					return
 				}
@@ -122,54 +216,52 @@ func HandleInitiate(c *gin.Context) {
 			resp.URL = u.String()
 		}
 	} else {
-		// Check if an upload has already been started.
-		respUploads, err := svr.ListMultipartUploads(&s3.ListMultipartUploadsInput{
-			Bucket:     aws.String(svr.Config.Bucket),
-			Prefix:     aws.String(resp.ETag),
-			MaxUploads: aws.Int64(1),
-		})
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to get uploads"})
-			return
-		}
-
-		if len(respUploads.Uploads) > 0 {
-			upload := respUploads.Uploads[0]
-
-			respParts, err := svr.ListParts(&s3.ListPartsInput{
-				Bucket:   aws.String(svr.Config.Bucket),
-				Key:      aws.String(resp.ETag),
-				UploadId: upload.UploadId,
-			})
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "failed to get parts"})
+		if quotas != nil {
+			if err := quotas.Reserve(Identity(c), quota.Key(req.Server, resp.ETag), req.Size); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 				return
 			}
+		}
 
-			for _, p := range respParts.Parts {
-				resp.Parts = append(resp.Parts, part{
-					Number: *p.PartNumber,
-					ETag:   strings.Trim(*p.ETag, "\""),
-					Length: int(*p.Size),
-				})
+		// Only the uploader who started an upload may resume it.
+		// So we never reveal in-progress uploads to clients without a valid session.
+		var resumed bool
+		if sess, err := signer.Verify(req.Session); err == nil && sess.Server == req.Server && sess.ETag == resp.ETag && sess.Content == req.ETag && sess.Identity == Identity(c) {
+			if parts, err := svr.ListAllParts(c.Request.Context(), resp.ETag, sess.UploadID); err == nil {
+				for _, p := range parts {
+					resp.Parts = append(resp.Parts, part{
+						Number: *p.PartNumber,
+						ETag:   strings.Trim(*p.ETag, "\""),
+						Length: int(*p.Size),
+					})
+				}
+
+				resp.UploadID = sess.UploadID
+				resumed = true
 			}
+		}
 
-			resp.UploadID = *upload.UploadId
-		} else {
+		if !resumed {
 			meta := map[string]string{
 				"Original-Uploader": c.ClientIP(),
 				"Original-Filename": req.FileName,
 			}
 
+			if req.Encryption != nil {
+				maps.Copy(meta, req.Encryption.Metadata())
+			}
+
 			// Shorten link.
 			if req.ShortURL {
 				if shortener == nil {
+					releaseQuota(c, req.Server, resp.ETag)
 					c.JSON(http.StatusBadRequest, gin.H{"error": "shortened URL requested but nut supported"})
 					return
 				}
 
-				u, err = shortener.Shorten(u)
+				u, err = shortener.Shorten(c.Request.Context(), u)
 				if err != nil {
+					releaseQuota(c, req.Server, resp.ETag)
 					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 					return
 				}
@@ -177,21 +269,67 @@ func HandleInitiate(c *gin.Context) {
 				meta["Original-Short-Url"] = u.String()
 			}
 
-			respCreateMPU, err := svr.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+			createMPU := &s3.CreateMultipartUploadInput{
 				Bucket:      aws.String(svr.Config.Bucket),
 				Key:         aws.String(resp.ETag),
 				Metadata:    aws.StringMap(meta),
 				ContentType: aws.String(req.Type),
-			})
+			}
+
+			svr.Encrypt(createMPU, key)
+
+			respCreateMPU, err := svr.CreateMultipartUploadWithContext(c.Request.Context(), createMPU)
 			if err != nil {
+				releaseQuota(c, req.Server, resp.ETag)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
 			resp.URL = u.String()
+
+			// The key is only passed in the fragment which is never sent to GoSƐ or the shortener.
+			if key != nil {
+				resp.URL += "#key=" + server.EncodeCustomerKey(key)
+			}
 			resp.UploadID = *respCreateMPU.UploadId
 		}
+
+		if resp.Session, err = signer.Issue(req.Server, resp.ETag, req.ETag, resp.UploadID, Identity(c), key); err != nil {
+			releaseQuota(c, req.Server, resp.ETag)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue session"})
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, resp)
 }
+
+// selectServer chooses a healthy server of the requested group for the "auto" server.
+// Resumed uploads stay on the server of their session as long as it is healthy.
+func selectServer(c *gin.Context, req *initiateRequest) (string, error) {
+	svrs := c.MustGet("servers").(server.List)
+	signer := c.MustGet("sessions").(*session.Signer)
+	checker := c.MustGet("health").(*health.Checker)
+
+	if req.Group == "" {
+		req.Group = config.DefaultGroup
+	}
+
+	healthy := func(svr server.Server) bool {
+		return checker.Status(svr.Component()) != health.StatusDown
+	}
+
+	// The ETag of the session is a random one for uploads encrypted with SSE-C.
+	if sess, err := signer.Verify(req.Session); err == nil && sess.Content == req.ETag && sess.Identity == Identity(c) {
+		if svr, ok := svrs[sess.Server]; ok && svr.Config.Group == req.Group && healthy(svr) {
+			return svr.Config.ID, nil
+		}
+	}
+
+	svr, err := svrs.Select(req.Group, healthy)
+	if err != nil {
+		return "", err
+	}
+
+	return svr.Config.ID, nil
+}
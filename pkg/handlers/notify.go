@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/containrrr/shoutrrr/pkg/types"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/notifier"
+)
+
+// notifyAdmins sends a notification about an object to the configured notification URLs.
+// In contrast to upload notifications, admin notifications are always sent.
+func notifyAdmins(ctx context.Context, cfg *config.Config, url string, obj *s3.HeadObjectOutput, sums map[string]string, title string) {
+	if cfg.Notification == nil || len(cfg.Notification.URLs) == 0 {
+		return
+	}
+
+	notif, err := notifier.NewNotifier(cfg.Notification.Template, cfg.Notification.URLs...)
+	if err != nil {
+		logging.Logger(ctx).Error("Failed to create notification sender", "error", err)
+		return
+	}
+
+	if err := notif.Notify(ctx, url, obj, sums, types.Params{
+		"Title": title,
+	}); err != nil {
+		logging.Logger(ctx).Error("Failed to send notification", "error", err)
+	}
+}
+
+// pseudoObject describes a file for notifications which has not been stored yet.
+func pseudoObject(fileName, mimeType, uploader string, size int64) *s3.HeadObjectOutput {
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(mimeType),
+		LastModified:  aws.Time(time.Now()),
+		Metadata: aws.StringMap(map[string]string{
+			"Original-Filename": fileName,
+			"Original-Uploader": uploader,
+		}),
+	}
+}
@@ -4,26 +4,49 @@
 package handlers
 
 import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/logging"
 	"github.com/stv0g/gose/pkg/server"
 	"github.com/stv0g/gose/pkg/utils"
 )
 
+var (
+	errInvalidPartNumber = errors.New("invalid part number")
+	errInvalidPartSize   = errors.New("invalid part size")
+	errInvalidChecksum   = errors.New("invalid checksum")
+)
+
 type partRequest struct {
 	Server   string `json:"server"`
 	ETag     string `json:"etag"`
 	UploadID string `json:"upload_id"`
+	Session  string `json:"session"`
 	Number   int    `json:"number"`
 	Length   int    `json:"length"`
+
+	// Hex-encoded MD5 digest of the part (equal to its ETag).
+	Checksum string `json:"checksum"`
+
+	// Optional hex-encoded SHA256 digest of the part.
+	ChecksumSHA256 string `json:"checksum_sha256,omitempty"`
 }
 
 type partResponse struct {
 	URL string `json:"url"`
+
+	// Headers which must be sent by the client along with the upload of the part.
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // HandlePart initiates a new upload
@@ -42,37 +65,92 @@ func HandlePart(c *gin.Context) {
 		return
 	}
 
-	if req.Number <= 0 || req.Number >= utils.MaxPartCount {
-		c.JSON(http.StatusNotFound, gin.H{"error": "invalid part number"})
+	if !utils.IsValidETag(req.ETag) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
 		return
 	}
 
-	if !utils.IsValidETag(req.ETag) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+	logging.With(c, "etag", req.ETag)
+
+	sess := checkSession(c, req.Session, req.Server, req.ETag, req.UploadID)
+	if sess == nil {
 		return
 	}
 
-	if req.Length > int(svr.Config.PartSize) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "invalid part size"})
+	u, hdrs, err := presignPart(svr, &req, svr.Config.PresignValidity, sess.Key)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// For creating PutObject presigned URLs.
-	partReq, _ := svr.UploadPartRequest(&s3.UploadPartInput{
+	c.JSON(http.StatusOK, partResponse{
+		URL:     u,
+		Headers: hdrs,
+	})
+}
+
+// presignPart validates a part request and creates a presigned URL for the upload of the part.
+// The SSE-C key is nil unless the upload is encrypted with a customer key.
+func presignPart(svr server.Server, req *partRequest, validity time.Duration, key []byte) (string, map[string]string, error) {
+	if req.Number <= 0 || req.Number >= utils.MaxPartCount {
+		return "", nil, errInvalidPartNumber
+	}
+
+	if req.Length <= 0 || req.Length > int(svr.Config.PartSize) {
+		return "", nil, errInvalidPartSize
+	}
+
+	md5Sum, err := hex.DecodeString(req.Checksum)
+	if err != nil || len(md5Sum) != md5.Size {
+		return "", nil, errInvalidChecksum
+	}
+
+	// Including the Content-MD5 in the signature lets S3 reject corrupted parts.
+	partInput := &s3.UploadPartInput{
 		Bucket:        aws.String(svr.Config.Bucket),
 		Key:           aws.String(req.ETag),
 		UploadId:      aws.String(req.UploadID),
 		ContentLength: aws.Int64(int64(req.Length)),
+		ContentMD5:    aws.String(base64.StdEncoding.EncodeToString(md5Sum)),
 		PartNumber:    aws.Int64(int64(req.Number)),
-	})
+	}
 
-	u, _, err := partReq.PresignRequest(1 * time.Hour)
+	partInput.SSECustomerAlgorithm, partInput.SSECustomerKey = server.CustomerKey(key)
+
+	if req.ChecksumSHA256 != "" {
+		sha256Sum, err := hex.DecodeString(req.ChecksumSHA256)
+		if err != nil || len(sha256Sum) != sha256.Size {
+			return "", nil, errInvalidChecksum
+		}
+
+		partInput.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(sha256Sum))
+	}
+
+	// For creating PutObject presigned URLs.
+	partReq, _ := svr.UploadPartRequest(partInput)
+
+	u, hdr, err := partReq.PresignRequest(validity)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return "", nil, err
 	}
 
-	c.JSON(http.StatusOK, partResponse{
-		URL: u,
-	})
+	return u, signedHeaders(hdr), nil
+}
+
+// signedHeaders returns the headers which have been included in the signature
+// of a presigned request and therefore must be sent by the client.
+func signedHeaders(hdr http.Header) map[string]string {
+	hdrs := map[string]string{}
+	for k, v := range hdr {
+		// The signer uses lower-case header names.
+		switch http.CanonicalHeaderKey(k) {
+		// These headers are set by the client itself.
+		case "Host", "Content-Length":
+			continue
+		}
+
+		hdrs[k] = strings.Join(v, ",")
+	}
+
+	return hdrs
 }
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+const (
+	// MaxPartsPerBatch is the maximum number of parts which can be presigned by a single request.
+	MaxPartsPerBatch = 1000
+)
+
+type partsRequest struct {
+	Server   string `json:"server"`
+	ETag     string `json:"etag"`
+	UploadID string `json:"upload_id"`
+	Session  string `json:"session"`
+	Parts    []part `json:"parts"`
+
+	// Optional validity of the presigned URLs in seconds.
+	// It is limited by the server's presign_validity setting.
+	Validity int `json:"validity,omitempty"`
+}
+
+type partsResponse struct {
+	Parts   []part    `json:"parts"`
+	Expires time.Time `json:"expires"`
+}
+
+// HandleParts presigns the upload of multiple parts at once.
+func HandleParts(c *gin.Context) {
+	svrs := c.MustGet("servers").(server.List)
+
+	var req partsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "malformed request"})
+		return
+	}
+
+	svr, ok := svrs[req.Server]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invalid server"})
+		return
+	}
+
+	if !utils.IsValidETag(req.ETag) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+		return
+	}
+
+	logging.With(c, "etag", req.ETag)
+
+	sess := checkSession(c, req.Session, req.Server, req.ETag, req.UploadID)
+	if sess == nil {
+		return
+	}
+
+	if len(req.Parts) == 0 || len(req.Parts) > MaxPartsPerBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("number of parts must be between 1 and %d", MaxPartsPerBatch)})
+		return
+	}
+
+	validity := svr.Config.PresignValidity
+	if v := time.Duration(req.Validity) * time.Second; v > 0 && v < validity {
+		validity = v
+	}
+
+	resp := partsResponse{
+		Parts:   []part{},
+		Expires: time.Now().Add(validity),
+	}
+
+	for _, p := range req.Parts {
+		u, hdrs, err := presignPart(svr, &partRequest{
+			Server:   req.Server,
+			ETag:     req.ETag,
+			UploadID: req.UploadID,
+			Number:   int(p.Number),
+			Length:   p.Length,
+			Checksum: p.ETag,
+		}, validity, sess.Key)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("part %d: %s", p.Number, err)})
+			return
+		}
+
+		p.URL = u
+		p.Headers = hdrs
+
+		resp.Parts = append(resp.Parts, p)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
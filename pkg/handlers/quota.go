@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/quota"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+// HandleQuota returns the current usage and limits of the client.
+func HandleQuota(c *gin.Context) {
+	quotas := c.MustGet("quota").(*quota.Manager)
+
+	if quotas == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "quotas are not enabled"})
+		return
+	}
+
+	st, err := quotas.Status(Identity(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, st)
+}
+
+// releaseQuota frees the reservation of an upload which has not been started or has been aborted.
+func releaseQuota(c *gin.Context, svr, etag string) {
+	quotas := c.MustGet("quota").(*quota.Manager)
+	if quotas == nil {
+		return
+	}
+
+	if err := quotas.Release(Identity(c), quota.Key(svr, etag)); err != nil {
+		logging.FromContext(c).Error("Failed to release quota", "error", err)
+	}
+}
+
+// forgetQuota removes a deleted object from the active storage of its uploader.
+func forgetQuota(c *gin.Context, uploader, svr, etag string) {
+	quotas := c.MustGet("quota").(*quota.Manager)
+	if quotas == nil || uploader == "" {
+		return
+	}
+
+	if err := quotas.Forget(uploader, quota.Key(svr, etag)); err != nil {
+		logging.FromContext(c).Error("Failed to update quota of deleted object", "error", err)
+	}
+}
+
+// deleteObject deletes an object and removes it from the quota of its uploader.
+func deleteObject(c *gin.Context, svr server.Server, etag string) error {
+	var uploader string
+	if obj, err := svr.GetObjectInfo(c.Request.Context(), etag); err == nil {
+		uploader = obj.Metadata["Original-Uploader"]
+	}
+
+	if err := svr.DeleteObjectByKey(c.Request.Context(), etag); err != nil {
+		return err
+	}
+
+	forgetQuota(c, uploader, svr.Config.ID, etag)
+
+	return nil
+}
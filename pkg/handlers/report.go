@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+	"slices"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/abuse"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+const (
+	// MaxReportCommentLength is the maximum length of the comment of an abuse report.
+	MaxReportCommentLength = 4096
+)
+
+// ReportReasons are the accepted reasons for abuse reports.
+var ReportReasons = []string{"copyright", "malware", "illegal", "spam", "other"}
+
+//go:embed report.html
+var reportPage []byte
+
+type reportRequest struct {
+	Reason  string `json:"reason"`
+	Comment string `json:"comment"`
+}
+
+// HandleReportPage serves a form for reporting a file.
+func HandleReportPage(c *gin.Context) {
+	c.Data(http.StatusOK, gin.MIMEHTML, reportPage)
+}
+
+// HandleReport records an abuse report for a file and notifies the admins.
+func HandleReport(c *gin.Context) {
+	svrs := c.MustGet("servers").(server.List)
+	cfg := c.MustGet("config").(*config.Config)
+	abuses := c.MustGet("abuse").(*abuse.Manager)
+
+	svr, ok := svrs[c.Param("server")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invalid server"})
+		return
+	}
+
+	etag := c.Param("etag")
+	if !utils.IsValidETag(etag) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+		return
+	}
+
+	var req reportRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "malformed request"})
+		return
+	}
+
+	if !slices.Contains(ReportReasons, req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reason"})
+		return
+	}
+
+	if len(req.Comment) > MaxReportCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "comment too long"})
+		return
+	}
+
+	// Files encrypted with SSE-C can be reported without their key.
+	obj, err := svr.StatObject(c.Request.Context(), etag)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+
+	// Their meta-data is unknown. So we tell the admins at least the name from the link.
+	if obj.Metadata == nil {
+		obj.Metadata = map[string]*string{}
+	}
+
+	if _, ok := obj.Metadata["Original-Filename"]; !ok {
+		obj.Metadata["Original-Filename"] = aws.String(c.Param("filename"))
+	}
+
+	r := &abuse.Report{
+		Server:   svr.Config.ID,
+		ETag:     etag,
+		FileName: c.Param("filename"),
+		Reason:   req.Reason,
+		Comment:  req.Comment,
+		Reporter: c.ClientIP(),
+	}
+
+	if err := abuses.Report(r); err != nil {
+		logging.FromContext(c).Error("Failed to store abuse report", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store report"})
+		return
+	}
+
+	go notifyAdmins(context.WithoutCancel(c.Request.Context()), cfg, svr.GetObjectURL(etag).String(), obj, nil, "Abuse report: "+req.Reason)
+
+	c.JSON(http.StatusOK, r)
+}
+
+// checkBlocked responds with 451 if an ETag is on the block list.
+func checkBlocked(c *gin.Context, etag string) bool {
+	abuses := c.MustGet("abuse").(*abuse.Manager)
+
+	b, err := abuses.Blocked(etag)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check block list"})
+		return false
+	} else if b != nil {
+		c.JSON(http.StatusUnavailableForLegalReasons, gin.H{"error": "file has been blocked"})
+		return false
+	}
+
+	return true
+}
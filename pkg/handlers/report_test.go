@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/abuse"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/handlers"
+	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/store"
+)
+
+func TestReportCustomerKey(t *testing.T) {
+	const key = "d41d8cd98f00b204e9800998ecf8427e-1"
+
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		// S3 requires the key of SSE-C objects for HEAD requests.
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusBadRequest)
+
+		case r.URL.Path == "/bucket" && r.URL.Query().Get("list-type") == "2":
+			io.WriteString(w, `<ListBucketResult><Contents><Key>`+key+`</Key><Size>42</Size><LastModified>2024-01-01T00:00:00Z</LastModified></Contents></ListBucketResult>`)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s3.Close()
+
+	notifications := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		notifications <- string(body)
+	}))
+	defer hook.Close()
+
+	svrs := server.NewList([]config.S3Server{
+		{
+			S3ServerConfig: config.S3ServerConfig{
+				ID:       "s1",
+				PartSize: config.MinPartSize,
+			},
+			Endpoint:   strings.TrimPrefix(s3.URL, "http://"),
+			Bucket:     "bucket",
+			Region:     "us-east-1",
+			PathStyle:  true,
+			NoSSL:      true,
+			AccessKey:  "access",
+			SecretKey:  "secret",
+			Encryption: &config.EncryptionConfig{Mode: config.EncryptionSSEC},
+		},
+	})
+
+	cfg := &config.Config{
+		Notification: &config.NotificationConfig{
+			URLs:     []string{"generic://" + strings.TrimPrefix(hook.URL, "http://") + "/?disabletls=yes"},
+			Template: "{{.FileName}} {{.FileSize}} {{.FileType}}",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/report/:server/:etag/:filename", func(c *gin.Context) {
+		c.Set("servers", svrs)
+		c.Set("config", cfg)
+		c.Set("abuse", abuse.NewManager(store.NewMemory(), time.Minute))
+	}, handlers.HandleReport)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/report/s1/"+key+"/file.txt", strings.NewReader(`{"reason":"spam"}`))
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body)
+	}
+
+	select {
+	case n := <-notifications:
+		if !strings.Contains(n, "file.txt 42") {
+			t.Fatalf("Unexpected notification: %s", n)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Admins have not been notified")
+	}
+}
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/session"
+)
+
+// Identity returns an identifier for the uploader.
+// It is used for sessions, quotas and bans.
+func Identity(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// checkSession verifies that the session token has been issued to the client
+// for the given upload. It responds with an error and returns nil if the check fails.
+func checkSession(c *gin.Context, token, svr, etag, uploadID string) *session.Session {
+	signer := c.MustGet("sessions").(*session.Signer)
+
+	sess, err := signer.Verify(token)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil
+	}
+
+	if sess.Server != svr || sess.ETag != etag || sess.UploadID != uploadID || sess.Identity != Identity(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "session does not match upload"})
+		return nil
+	}
+
+	return sess
+}
@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/policy"
+	"github.com/stv0g/gose/pkg/quota"
+	"github.com/stv0g/gose/pkg/server"
+	"github.com/stv0g/gose/pkg/session"
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+type transferRequest struct {
+	// Server is the ID of the target server.
+	// It defaults to the current server for only changing the expiration class.
+	Server string `json:"server"`
+
+	// Expiration is the expiration class at the target server.
+	// It defaults to the first class of the target server.
+	Expiration *string `json:"expiration"`
+
+	// Delete removes the object from the current server once it has been copied.
+	Delete bool `json:"delete"`
+
+	// OwnerToken is the token returned by the completion or a previous transfer of the upload.
+	// It is not required for admins.
+	OwnerToken string `json:"owner_token"`
+}
+
+type transferResponse struct {
+	Server string `json:"server"`
+	ETag   string `json:"etag"`
+	URL    string `json:"url"`
+
+	// OwnerToken replaces the token of the request as the upload has a new server or expiration.
+	OwnerToken string `json:"owner_token,omitempty"`
+}
+
+// HandleTransfer copies or moves an upload to another server or expiration class.
+// Only the owner of the token issued when completing the upload may transfer it.
+func HandleTransfer(c *gin.Context) {
+	transfer(c, false)
+}
+
+// HandleAdminTransfer copies or moves any upload to another server or expiration class.
+// In contrast to HandleTransfer, policies and quotas of the target server do not apply.
+func HandleAdminTransfer(c *gin.Context) {
+	transfer(c, true)
+}
+
+func transfer(c *gin.Context, admin bool) {
+	svrs := c.MustGet("servers").(server.List)
+	cfg := c.MustGet("config").(*config.Config)
+	quotas := c.MustGet("quota").(*quota.Manager)
+	signer := c.MustGet("sessions").(*session.Signer)
+
+	etag := c.Param("etag")
+
+	src, ok := svrs[c.Param("server")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invalid server"})
+		return
+	}
+
+	if !utils.IsValidETag(etag) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid etag"})
+		return
+	}
+
+	var req transferRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "malformed request"})
+		return
+	}
+
+	if req.Server == "" {
+		req.Server = src.Config.ID
+	}
+
+	dst, ok := svrs[req.Server]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invalid target server"})
+		return
+	}
+
+	// GoSƐ does not know the keys of objects encrypted with SSE-C.
+	if src.UsesCustomerKeys() || dst.UsesCustomerKeys() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "can not transfer uploads encrypted with customer keys"})
+		return
+	}
+
+	same := dst.Config.ID == src.Config.ID
+	if same && req.Delete {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "can not move an upload to its own server"})
+		return
+	}
+
+	if !admin && !checkBlocked(c, etag) {
+		return
+	}
+
+	// Client IPs are shared behind NATs and proxies. So they can not prove the ownership.
+	if !admin {
+		if sess, err := signer.VerifyOwner(req.OwnerToken); err != nil || sess.Server != src.Config.ID || sess.ETag != etag {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the uploader may transfer this file"})
+			return
+		}
+	}
+
+	obj, err := src.HeadObjectWithContext(c.Request.Context(), &s3.HeadObjectInput{
+		Bucket: aws.String(src.Config.Bucket),
+		Key:    aws.String(etag),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get object"})
+		return
+	}
+
+	fileName := aws.StringValue(obj.Metadata["Original-Filename"])
+	size := aws.Int64Value(obj.ContentLength)
+
+	annotate(c, src.Config.ID, etag, fileName, size)
+
+	if !admin {
+		if err := policy.Check(&dst.Config.Policy, aws.StringValue(obj.ContentType), fileName); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		if size > int64(dst.Config.MaxUploadSize) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file exceeds max upload size of target server"})
+			return
+		}
+	}
+
+	var exp *config.Expiration
+	if req.Expiration == nil {
+		if len(dst.Config.Expiration) > 0 {
+			exp = &dst.Config.Expiration[0]
+		}
+	} else if exp = dst.GetExpirationClass(*req.Expiration); exp == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expiration class"})
+		return
+	}
+
+	var expires time.Time
+	tags := map[string]string{}
+	if exp != nil {
+		tags["expiration"] = exp.ID
+		expires = time.Now().AddDate(0, 0, int(exp.Days))
+	}
+
+	if same {
+		err = src.SetTags(c.Request.Context(), etag, tags)
+	} else {
+		err = src.CopyTo(c.Request.Context(), dst, etag, tags)
+	}
+	if err != nil {
+		logging.FromContext(c).Error("Failed to transfer object", "target", dst.Config.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to transfer object"})
+		return
+	}
+
+	// The copy counts towards the quota of the uploader like a new upload.
+	if !admin && !same && quotas != nil {
+		if err := quotas.Commit(Identity(c), quota.Key(dst.Config.ID, etag), size, expires); err != nil {
+			if err := dst.DeleteObjectByKey(c.Request.Context(), etag); err != nil {
+				logging.FromContext(c).Error("Failed to delete copied object", "error", err)
+			}
+
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.Delete {
+		if err := src.DeleteObjectByKey(c.Request.Context(), etag); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete source object"})
+			return
+		}
+	}
+
+	logging.FromContext(c).Info("Transferred object", "target", dst.Config.ID, "expiration", tags["expiration"], "deleted", req.Delete)
+
+	u, _ := url.Parse(cfg.BaseURL)
+	u.Path += filepath.Join("api/v1/download", dst.Config.ID, etag, fileName)
+
+	owner, err := signer.IssueOwner(dst.Config.ID, etag, expires)
+	if err != nil {
+		logging.FromContext(c).Error("Failed to issue owner token", "error", err)
+	}
+
+	c.JSON(http.StatusOK, &transferResponse{
+		Server:     dst.Config.ID,
+		ETag:       etag,
+		URL:        u.String(),
+		OwnerToken: owner,
+	})
+}
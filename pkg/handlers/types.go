@@ -9,4 +9,6 @@ type part struct {
 	URL    string `json:"url,omitempty"`
 	Length int    `json:"length,omitempty"`
 	Offset uint64 `json:"offset,omitempty"`
+
+	Headers map[string]string `json:"headers,omitempty"`
 }
@@ -0,0 +1,281 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package health periodically checks the components GoSƐ depends on.
+// Probes only read the cached results, so they are cheap and never block on a slow backend.
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status of a component.
+const (
+	StatusUp      = "up"
+	StatusDown    = "down"
+	StatusUnknown = "unknown"
+)
+
+// ErrStale is reported for jobs which did not run within their expected interval.
+var ErrStale = errors.New("no run within expected interval")
+
+// CheckFunc checks a single component.
+// The context is canceled once the timeout of the checker has passed.
+type CheckFunc func(ctx context.Context) error
+
+// Component is the status of a single component.
+type Component struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+
+	// Checked is the time of the last check or the last run of a job.
+	Checked *time.Time `json:"checked,omitempty"`
+}
+
+// Report is the status of all components.
+type Report struct {
+	// Status is up if all critical components are up.
+	Status     string      `json:"status"`
+	Components []Component `json:"components"`
+}
+
+// Ready returns true if all critical components are up.
+func (r *Report) Ready() bool {
+	return r.Status == StatusUp
+}
+
+type check struct {
+	critical bool
+	fn       CheckFunc
+
+	err     error
+	checked time.Time
+}
+
+// Job tracks the runs of a background task.
+type Job struct {
+	critical bool
+	maxAge   time.Duration
+	started  time.Time
+
+	err     error
+	lastRun time.Time
+	mu      sync.Mutex
+}
+
+// Checker runs checks in the background and caches their results.
+type Checker struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	checks map[string]*check
+	jobs   map[string]*Job
+	mu     sync.RWMutex
+}
+
+// NewChecker creates a new checker.
+func NewChecker(interval, timeout time.Duration) *Checker {
+	return &Checker{
+		interval: interval,
+		timeout:  timeout,
+		checks:   map[string]*check{},
+		jobs:     map[string]*Job{},
+	}
+}
+
+// Add registers a check.
+// Components which have not been checked yet are reported as unknown.
+func (c *Checker) Add(name string, critical bool, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = &check{
+		critical: critical,
+		fn:       fn,
+	}
+}
+
+// Job registers a background task which is expected to run at least once per maxAge.
+// It is reported as down if the last run failed or is older than maxAge.
+func (c *Checker) Job(name string, critical bool, maxAge time.Duration) *Job {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	j := &Job{
+		critical: critical,
+		maxAge:   maxAge,
+		started:  time.Now(),
+	}
+
+	c.jobs[name] = j
+
+	return j
+}
+
+// Start runs all checks once and then periodically in the background.
+func (c *Checker) Start() {
+	c.Run()
+
+	go func() {
+		for range time.Tick(c.interval) {
+			c.Run()
+		}
+	}()
+}
+
+// Run runs all checks concurrently and waits for their completion.
+func (c *Checker) Run() {
+	c.mu.RLock()
+	checks := make(map[string]*check, len(c.checks))
+	for name, chk := range c.checks {
+		checks[name] = chk
+	}
+	c.mu.RUnlock()
+
+	wg := sync.WaitGroup{}
+	for name, chk := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+			defer cancel()
+
+			err := chk.fn(ctx)
+			if err != nil {
+				slog.Warn("Health check failed", "component", name, "error", err)
+			}
+
+			c.mu.Lock()
+			chk.err = err
+			chk.checked = time.Now()
+			c.mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+}
+
+// Status returns the cached status of a checked component.
+func (c *Checker) Status(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	chk, ok := c.checks[name]
+	switch {
+	case !ok || chk.checked.IsZero():
+		return StatusUnknown
+	case chk.err != nil:
+		return StatusDown
+	default:
+		return StatusUp
+	}
+}
+
+// Report returns the cached status of all components.
+func (c *Checker) Report() *Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	r := &Report{
+		Status:     StatusUp,
+		Components: []Component{},
+	}
+
+	for name, chk := range c.checks {
+		comp := Component{
+			Name:     name,
+			Critical: chk.critical,
+		}
+
+		if chk.checked.IsZero() {
+			comp.Status = StatusUnknown
+		} else {
+			checked := chk.checked
+			comp.Checked = &checked
+			comp.setError(chk.err)
+		}
+
+		r.add(comp)
+	}
+
+	for name, j := range c.jobs {
+		r.add(j.component(name))
+	}
+
+	sort.Slice(r.Components, func(i, j int) bool {
+		return r.Components[i].Name < r.Components[j].Name
+	})
+
+	return r
+}
+
+func (r *Report) add(comp Component) {
+	if comp.Critical && comp.Status != StatusUp {
+		r.Status = StatusDown
+	}
+
+	r.Components = append(r.Components, comp)
+}
+
+func (comp *Component) setError(err error) {
+	if err != nil {
+		comp.Status = StatusDown
+		comp.Error = err.Error()
+	} else {
+		comp.Status = StatusUp
+	}
+}
+
+// Done records a run of the job.
+// It does nothing for a nil job, so tasks do not need to check if they are monitored.
+func (j *Job) Done(err error) {
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.err = err
+	j.lastRun = time.Now()
+}
+
+func (j *Job) component(name string) Component {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	comp := Component{
+		Name:     name,
+		Critical: j.critical,
+	}
+
+	lastRun := j.lastRun
+
+	switch {
+	case j.lastRun.IsZero():
+		// Give the job some time for its first run.
+		if time.Since(j.started) > j.maxAge {
+			comp.setError(ErrStale)
+		} else {
+			comp.Status = StatusUnknown
+		}
+
+	case time.Since(j.lastRun) > j.maxAge:
+		comp.Checked = &lastRun
+		comp.setError(ErrStale)
+
+	default:
+		comp.Checked = &lastRun
+		comp.setError(j.err)
+	}
+
+	return comp
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stv0g/gose/pkg/health"
+)
+
+func status(r *health.Report) map[string]string {
+	s := map[string]string{}
+	for _, comp := range r.Components {
+		s[comp.Name] = comp.Status
+	}
+
+	return s
+}
+
+func TestChecker(t *testing.T) {
+	c := health.NewChecker(time.Minute, 50*time.Millisecond)
+
+	c.Add("ok", true, func(context.Context) error {
+		return nil
+	})
+
+	c.Add("slow", false, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if r := c.Report(); r.Ready() || status(r)["ok"] != health.StatusUnknown {
+		t.Errorf("Unchecked critical component must not be ready: %+v", r)
+	}
+
+	c.Run()
+
+	r := c.Report()
+	if !r.Ready() {
+		t.Errorf("Failure of non-critical component must not affect readiness: %+v", r)
+	}
+
+	if s := status(r); s["ok"] != health.StatusUp || s["slow"] != health.StatusDown {
+		t.Errorf("Unexpected status: %v", s)
+	}
+
+	c.Add("failing", true, func(context.Context) error {
+		return errors.New("failed")
+	})
+	c.Run()
+
+	if r := c.Report(); r.Ready() {
+		t.Errorf("Failure of critical component must affect readiness: %+v", r)
+	}
+}
+
+func TestJob(t *testing.T) {
+	c := health.NewChecker(time.Minute, time.Second)
+
+	j := c.Job("janitor", true, 50*time.Millisecond)
+
+	if s := status(c.Report()); s["janitor"] != health.StatusUnknown {
+		t.Errorf("Unexpected status before first run: %s", s["janitor"])
+	}
+
+	j.Done(errors.New("failed"))
+	if s := status(c.Report()); s["janitor"] != health.StatusDown {
+		t.Errorf("Unexpected status after failed run: %s", s["janitor"])
+	}
+
+	j.Done(nil)
+	if r := c.Report(); !r.Ready() {
+		t.Errorf("Unexpected status after successful run: %+v", r)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if r := c.Report(); r.Ready() || r.Components[0].Error != health.ErrStale.Error() {
+		t.Errorf("Unexpected status of stale job: %+v", r)
+	}
+
+	// Runs of unmonitored tasks are ignored.
+	var none *health.Job
+	none.Done(nil)
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package logging provides structured logging with per-request loggers.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// RequestIDHeader is the header used to pass request IDs between services.
+	RequestIDHeader = "X-Request-ID"
+
+	contextKey = "logger"
+)
+
+type requestIDKey struct{}
+
+// Incoming request IDs are only accepted if they are reasonably short and printable.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// New creates a new logger writing to w.
+func New(cfg *config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %s", cfg.Level)
+	}
+
+	opts := &slog.HandlerOptions{
+		Level: level,
+	}
+
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format: %s", cfg.Format)
+	}
+}
+
+// Middleware assigns a request ID to each request and logs the request once it has been handled.
+// An existing X-Request-ID header of the client or a proxy is reused.
+// The ID of the current trace is logged if the request is traced.
+func Middleware(l *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDKey{}, id))
+		c.Set(contextKey, l.With("request_id", id))
+
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			With(c, "trace_id", sc.TraceID().String())
+		}
+
+		if etag := c.Param("etag"); etag != "" {
+			With(c, "etag", etag)
+		}
+
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+
+		FromContext(c).Log(c.Request.Context(), level, "Request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start),
+			"client_ip", c.ClientIP())
+	}
+}
+
+// FromContext returns the logger of the current request.
+func FromContext(c *gin.Context) *slog.Logger {
+	if l, ok := c.Get(contextKey); ok {
+		return l.(*slog.Logger)
+	}
+
+	return slog.Default()
+}
+
+// With adds attributes to the logger of the current request and returns it.
+func With(c *gin.Context, args ...any) *slog.Logger {
+	l := FromContext(c).With(args...)
+	c.Set(contextKey, l)
+
+	return l
+}
+
+// Logger returns the default logger including the request ID stored in the context.
+// It is used outside of handlers which have no access to the logger of the request.
+func Logger(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+
+	return slog.Default()
+}
+
+// RequestID returns the request ID stored in the context or an empty string.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/logging"
+)
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []config.LogConfig{
+		{Level: "verbose", Format: "text"},
+		{Level: "info", Format: "xml"},
+	} {
+		if _, err := logging.New(&cfg, &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	buf := &bytes.Buffer{}
+	l, err := logging.New(&config.LogConfig{Level: "info", Format: "json"}, buf)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %s", err)
+	}
+
+	var seen string
+
+	r := gin.New()
+	r.Use(logging.Middleware(l))
+	r.GET("/download/:etag", func(c *gin.Context) {
+		seen = logging.RequestID(c.Request.Context())
+		logging.With(c, "size", 123)
+	})
+
+	for _, c := range []struct {
+		header string
+		reused bool
+	}{
+		{"abc-123", true},
+		{"invalid id\n", false},
+		{"", false},
+	} {
+		buf.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, "/download/a-1", nil)
+		if c.header != "" {
+			req.Header.Set(logging.RequestIDHeader, c.header)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		id := w.Header().Get(logging.RequestIDHeader)
+		if id == "" || id != seen || (id == c.header) != c.reused {
+			t.Errorf("Unexpected request ID %q for header %q", id, c.header)
+		}
+
+		var entry map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("Failed to decode log: %s", err)
+		}
+
+		if entry["request_id"] != id || entry["etag"] != "a-1" || entry["size"] != 123.0 {
+			t.Errorf("Unexpected log entry: %v", entry)
+		}
+	}
+}
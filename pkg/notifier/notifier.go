@@ -5,6 +5,7 @@ package notifier
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"math"
 	"net"
@@ -13,11 +14,15 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/containrrr/shoutrrr"
 	"github.com/containrrr/shoutrrr/pkg/router"
 	"github.com/containrrr/shoutrrr/pkg/types"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/tracing"
 	"github.com/stv0g/gose/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type notifierArgs struct {
@@ -32,6 +37,7 @@ type notifierArgs struct {
 	ExpiryRuleID     string
 	ExpiryDate       time.Time
 	UploadDate       time.Time
+	Checksums        map[string]string
 }
 
 // Notifier sends notifications via various channels.
@@ -62,20 +68,23 @@ func NewNotifier(tpl string, urls ...string) (*Notifier, error) {
 }
 
 // Notify sends a notification.
-func (n *Notifier) Notify(url string, obj *s3.HeadObjectOutput, params types.Params) error {
+// The checksums are optional and might be nil if not (yet) calculated.
+func (n *Notifier) Notify(ctx context.Context, url string, obj *s3.HeadObjectOutput, sums map[string]string, params types.Params) error {
 	env, err := utils.EnvToMap()
 	if err != nil {
 		return fmt.Errorf("failed to get env: %w", err)
 	}
 
+	// The meta-data of objects encrypted with SSE-C is unknown.
 	data := notifierArgs{
-		FileName:      *obj.Metadata["Original-Filename"],
-		FileSize:      *obj.ContentLength,
-		FileSizeHuman: humanizeBytes(*obj.ContentLength),
-		FileType:      *obj.ContentType,
+		FileName:      aws.StringValue(obj.Metadata["Original-Filename"]),
+		FileSize:      aws.Int64Value(obj.ContentLength),
+		FileSizeHuman: humanizeBytes(aws.Int64Value(obj.ContentLength)),
+		FileType:      aws.StringValue(obj.ContentType),
 		Env:           env,
 		URL:           url,
-		UploadDate:    *obj.LastModified,
+		UploadDate:    aws.TimeValue(obj.LastModified),
+		Checksums:     sums,
 	}
 
 	if obj.Expiration != nil {
@@ -93,10 +102,10 @@ func (n *Notifier) Notify(url string, obj *s3.HeadObjectOutput, params types.Par
 		}
 	}
 
-	if upl, ok := obj.Metadata["Original-Uploader"]; ok {
+	if upl, ok := obj.Metadata["Original-Uploader"]; ok && upl != nil {
 		data.UploaderIP = *upl
 
-		if addrs, err := net.LookupAddr(data.UploaderIP); err != nil && len(addrs) > 0 {
+		if addrs, err := net.DefaultResolver.LookupAddr(ctx, data.UploaderIP); err != nil && len(addrs) > 0 {
 			data.UploaderHostname = addrs[0]
 		}
 	}
@@ -108,14 +117,19 @@ func (n *Notifier) Notify(url string, obj *s3.HeadObjectOutput, params types.Par
 
 	msg := tpl.String()
 
-	if errs := n.Send(msg, &params); errs != nil {
-		for _, err := range errs {
-			if err != nil {
-				return err
-			}
+	logging.Logger(ctx).Debug("Sending notification", "title", params["Title"], "file", data.FileName)
+
+	_, span := tracing.Start(ctx, "Notify", attribute.String("notifier.title", params["Title"]))
+
+	for _, err := range n.Send(msg, &params) {
+		if err != nil {
+			tracing.End(span, err)
+			return err
 		}
 	}
 
+	tracing.End(span, nil)
+
 	return nil
 }
 
@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package policy checks uploads against allowed and forbidden file types.
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/stv0g/gose/pkg/config"
+)
+
+// SniffLength is the number of bytes required for sniffing the content type.
+const SniffLength = 512
+
+// ErrForbidden is returned if a file violates the policy.
+var ErrForbidden = errors.New("file type not allowed")
+
+// magic contains signatures of executables which are not detected by http.DetectContentType.
+var magic = []struct {
+	prefix   []byte
+	mimeType string
+}{
+	{[]byte("MZ"), "application/x-msdownload"},
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("#!"), "text/x-shellscript"},
+}
+
+// Check validates the MIME type and file name extension of an upload against the policy.
+func Check(p *config.PolicyConfig, mimeType, fileName string) error {
+	if err := CheckType(p, mimeType); err != nil {
+		return err
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	if matchExtension(p.DeniedExtensions, ext) {
+		return fmt.Errorf("%w: extension %s is forbidden", ErrForbidden, ext)
+	}
+
+	if len(p.AllowedExtensions) > 0 && !matchExtension(p.AllowedExtensions, ext) {
+		return fmt.Errorf("%w: extension %s is not allowed", ErrForbidden, ext)
+	}
+
+	return nil
+}
+
+// CheckType validates a MIME type against the policy.
+func CheckType(p *config.PolicyConfig, mimeType string) error {
+	if mt, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mt
+	}
+
+	if matchType(p.DeniedTypes, mimeType) {
+		return fmt.Errorf("%w: type %s is forbidden", ErrForbidden, mimeType)
+	}
+
+	if len(p.AllowedTypes) > 0 && !matchType(p.AllowedTypes, mimeType) {
+		return fmt.Errorf("%w: type %s is not allowed", ErrForbidden, mimeType)
+	}
+
+	return nil
+}
+
+// Sniff detects the MIME type of a file based on its first bytes.
+func Sniff(data []byte) string {
+	for _, m := range magic {
+		if bytes.HasPrefix(data, m.prefix) {
+			return m.mimeType
+		}
+	}
+
+	return http.DetectContentType(data)
+}
+
+// matchType matches a MIME type against a list of patterns like "image/*".
+func matchType(patterns []string, mimeType string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), strings.ToLower(mimeType)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchExtension(exts []string, ext string) bool {
+	for _, e := range exts {
+		e = strings.ToLower(e)
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+
+		if e == ext {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package policy_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/policy"
+)
+
+func TestCheck(t *testing.T) {
+	p := &config.PolicyConfig{
+		AllowedTypes:     []string{"image/*", "application/pdf"},
+		DeniedExtensions: []string{"exe", ".BAT"},
+	}
+
+	for _, tc := range []struct {
+		mimeType string
+		fileName string
+		allowed  bool
+	}{
+		{"image/png", "cat.png", true},
+		{"application/pdf; charset=binary", "doc.pdf", true},
+		{"text/plain", "notes.txt", false},
+		{"image/png", "cat.exe", false},
+		{"image/png", "run.bat", false},
+	} {
+		err := policy.Check(p, tc.mimeType, tc.fileName)
+		if tc.allowed && err != nil {
+			t.Errorf("%s (%s) rejected: %s", tc.fileName, tc.mimeType, err)
+		} else if !tc.allowed && !errors.Is(err, policy.ErrForbidden) {
+			t.Errorf("%s (%s) not rejected", tc.fileName, tc.mimeType)
+		}
+	}
+}
+
+func TestSniff(t *testing.T) {
+	for data, expected := range map[string]string{
+		"MZ\x90\x00\x03":     "application/x-msdownload",
+		"\x7fELF\x02\x01":    "application/x-executable",
+		"%PDF-1.7":           "application/pdf",
+		"#!/bin/sh\necho hi": "text/x-shellscript",
+	} {
+		if mt := policy.Sniff([]byte(data)); mt != expected {
+			t.Errorf("Sniffed %s instead of %s", mt, expected)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package quota
+
+import "time"
+
+// SetNow replaces the clock of the manager in tests.
+func (m *Manager) SetNow(now func() time.Time) {
+	m.now = now
+}
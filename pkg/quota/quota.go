@@ -0,0 +1,245 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package quota limits the amount of data which can be uploaded per uploader.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/store"
+)
+
+// ErrExceeded is returned if an upload would exceed one of the quotas.
+var ErrExceeded = errors.New("quota exceeded")
+
+type upload struct {
+	Size    int64     `json:"size"`
+	Started time.Time `json:"started"`
+
+	// Day on which the size has been added to the daily usage.
+	Day string `json:"day"`
+}
+
+type object struct {
+	Size    int64     `json:"size"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// usage is the persisted usage record of a single uploader.
+type usage struct {
+	Day        string            `json:"day"`
+	BytesToday int64             `json:"bytes_today"`
+	Uploads    map[string]upload `json:"uploads"`
+	Objects    map[string]object `json:"objects"`
+}
+
+// Status describes the current usage and limits of an uploader.
+// Limits of zero are unlimited.
+type Status struct {
+	Identity string `json:"identity"`
+
+	BytesToday  int64 `json:"bytes_today"`
+	BytesPerDay int64 `json:"bytes_per_day"`
+
+	ConcurrentUploads    int `json:"concurrent_uploads"`
+	MaxConcurrentUploads int `json:"max_concurrent_uploads"`
+
+	ActiveStorage    int64 `json:"active_storage"`
+	MaxActiveStorage int64 `json:"max_active_storage"`
+}
+
+// Manager checks and tracks the quotas of uploaders.
+type Manager struct {
+	config *config.QuotaConfig
+	store  store.Store
+
+	// Uploads which have not been completed after this time do no longer count.
+	uploadTimeout time.Duration
+
+	now func() time.Time
+}
+
+// NewManager creates a new quota manager.
+func NewManager(cfg *config.QuotaConfig, st store.Store, uploadTimeout time.Duration) *Manager {
+	return &Manager{
+		config:        cfg,
+		store:         st,
+		uploadTimeout: uploadTimeout,
+		now:           time.Now,
+	}
+}
+
+// Key returns the key under which an upload or object is tracked.
+func Key(svr, etag string) string {
+	return svr + "/" + etag
+}
+
+// Reserve checks if an upload of the declared size is permitted and accounts for it.
+// Reserving an upload which is already in progress is a no-op.
+func (m *Manager) Reserve(identity, key string, size int64) error {
+	u := &usage{}
+	return store.Update(m.store, m.storeKey(identity), u, func() error {
+		now := m.prepare(u)
+
+		if _, ok := u.Uploads[key]; ok {
+			return nil
+		}
+
+		if max := m.config.ConcurrentUploads; max > 0 && len(u.Uploads)+1 > max {
+			return fmt.Errorf("%w: not more than %d concurrent uploads allowed", ErrExceeded, max)
+		}
+
+		u.Uploads[key] = upload{
+			Size:    size,
+			Started: now,
+			Day:     u.Day,
+		}
+		u.BytesToday += size
+
+		return m.check(u)
+	})
+}
+
+// Commit reconciles a reserved upload with the actual size of the completed object.
+func (m *Manager) Commit(identity, key string, size int64, expires time.Time) error {
+	u := &usage{}
+	return store.Update(m.store, m.storeKey(identity), u, func() error {
+		m.prepare(u)
+
+		// Uploads reserved on a previous day have already been accounted for.
+		if upl, ok := u.Uploads[key]; !ok {
+			u.BytesToday += size
+		} else if upl.Day == u.Day {
+			u.BytesToday += size - upl.Size
+		}
+
+		delete(u.Uploads, key)
+		u.Objects[key] = object{
+			Size:    size,
+			Expires: expires,
+		}
+
+		return m.check(u)
+	})
+}
+
+// Release removes a reserved upload which has been aborted.
+func (m *Manager) Release(identity, key string) error {
+	u := &usage{}
+	return store.Update(m.store, m.storeKey(identity), u, func() error {
+		m.prepare(u)
+
+		// The usage of previous days has already been reset.
+		if upl, ok := u.Uploads[key]; ok && upl.Day == u.Day {
+			u.BytesToday = max(0, u.BytesToday-upl.Size)
+		}
+
+		delete(u.Uploads, key)
+
+		return nil
+	})
+}
+
+// Forget removes a deleted object from the active storage of an uploader.
+// The uploaded bytes still count towards the daily usage.
+func (m *Manager) Forget(identity, key string) error {
+	u := &usage{}
+	return store.Update(m.store, m.storeKey(identity), u, func() error {
+		m.prepare(u)
+
+		delete(u.Objects, key)
+		delete(u.Uploads, key)
+
+		return nil
+	})
+}
+
+// Status returns the current usage and limits of an uploader.
+func (m *Manager) Status(identity string) (*Status, error) {
+	u := &usage{}
+	if err := m.store.Get(m.storeKey(identity), u); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	m.prepare(u)
+
+	return &Status{
+		Identity:             identity,
+		BytesToday:           u.BytesToday,
+		BytesPerDay:          int64(m.config.BytesPerDay),
+		ConcurrentUploads:    len(u.Uploads),
+		MaxConcurrentUploads: m.config.ConcurrentUploads,
+		ActiveStorage:        u.activeStorage(),
+		MaxActiveStorage:     int64(m.config.ActiveStorage),
+	}, nil
+}
+
+// check returns an error if the usage exceeds one of the byte limits.
+func (m *Manager) check(u *usage) error {
+	if max := int64(m.config.BytesPerDay); max > 0 && u.BytesToday > max {
+		return fmt.Errorf("%w: not more than %s per day allowed", ErrExceeded, units.HumanSize(float64(max)))
+	}
+
+	if max := int64(m.config.ActiveStorage); max > 0 && u.activeStorage() > max {
+		return fmt.Errorf("%w: not more than %s of active storage allowed", ErrExceeded, units.HumanSize(float64(max)))
+	}
+
+	return nil
+}
+
+// prepare initializes the usage record and removes outdated entries.
+func (m *Manager) prepare(u *usage) time.Time {
+	now := m.now()
+
+	if u.Uploads == nil {
+		u.Uploads = map[string]upload{}
+	}
+
+	if u.Objects == nil {
+		u.Objects = map[string]object{}
+	}
+
+	if day := now.UTC().Format(time.DateOnly); u.Day != day {
+		u.Day = day
+		u.BytesToday = 0
+	}
+
+	for k, upl := range u.Uploads {
+		if m.uploadTimeout > 0 && now.Sub(upl.Started) > m.uploadTimeout {
+			delete(u.Uploads, k)
+		}
+	}
+
+	for k, obj := range u.Objects {
+		if !obj.Expires.IsZero() && now.After(obj.Expires) {
+			delete(u.Objects, k)
+		}
+	}
+
+	return now
+}
+
+func (m *Manager) storeKey(identity string) string {
+	return "quota/" + url.PathEscape(identity) + ".json"
+}
+
+// activeStorage includes both completed and in-progress uploads.
+func (u *usage) activeStorage() int64 {
+	var total int64
+
+	for _, obj := range u.Objects {
+		total += obj.Size
+	}
+
+	for _, upl := range u.Uploads {
+		total += upl.Size
+	}
+
+	return total
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package quota_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/quota"
+	"github.com/stv0g/gose/pkg/store"
+)
+
+func TestQuota(t *testing.T) {
+	cfg := &config.QuotaConfig{
+		ConcurrentUploads: 2,
+	}
+	cfg.BytesPerDay.UnmarshalText([]byte("100B"))
+	cfg.ActiveStorage.UnmarshalText([]byte("150B"))
+
+	m := quota.NewManager(cfg, store.NewMemory(), time.Hour)
+
+	if err := m.Reserve("a", "svr/1", 40); err != nil {
+		t.Fatalf("Failed to reserve: %s", err)
+	}
+
+	// Reserving the same upload twice does not count twice.
+	if err := m.Reserve("a", "svr/1", 40); err != nil {
+		t.Fatalf("Failed to reserve: %s", err)
+	}
+
+	if err := m.Reserve("a", "svr/2", 70); !errors.Is(err, quota.ErrExceeded) {
+		t.Fatalf("Expected daily quota to be exceeded, got: %v", err)
+	}
+
+	if err := m.Reserve("a", "svr/2", 10); err != nil {
+		t.Fatalf("Failed to reserve: %s", err)
+	}
+
+	if err := m.Reserve("a", "svr/3", 10); !errors.Is(err, quota.ErrExceeded) {
+		t.Fatalf("Expected concurrent upload quota to be exceeded, got: %v", err)
+	}
+
+	// The actual size was smaller than declared.
+	if err := m.Commit("a", "svr/1", 20, time.Time{}); err != nil {
+		t.Fatalf("Failed to commit: %s", err)
+	}
+
+	st, err := m.Status("a")
+	if err != nil {
+		t.Fatalf("Failed to get status: %s", err)
+	}
+
+	if st.BytesToday != 30 || st.ConcurrentUploads != 1 || st.ActiveStorage != 30 {
+		t.Fatalf("Unexpected status: %+v", st)
+	}
+
+	if err := m.Release("a", "svr/2"); err != nil {
+		t.Fatalf("Failed to release: %s", err)
+	}
+
+	// Other uploaders are not affected.
+	if st, err := m.Status("b"); err != nil || st.BytesToday != 0 {
+		t.Fatalf("Unexpected status: %+v, %v", st, err)
+	}
+}
+
+func TestQuotaDayRollover(t *testing.T) {
+	cfg := &config.QuotaConfig{}
+	cfg.BytesPerDay.UnmarshalText([]byte("100B"))
+
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	m := quota.NewManager(cfg, store.NewMemory(), 24*time.Hour)
+	m.SetNow(func() time.Time { return now })
+
+	if err := m.Reserve("a", "svr/1", 80); err != nil {
+		t.Fatalf("Failed to reserve: %s", err)
+	}
+
+	if err := m.Reserve("a", "svr/2", 10); err != nil {
+		t.Fatalf("Failed to reserve: %s", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+
+	// Reservations of the previous day must not reduce the usage of the current one.
+	if err := m.Release("a", "svr/1"); err != nil {
+		t.Fatalf("Failed to release: %s", err)
+	}
+
+	if err := m.Commit("a", "svr/2", 10, time.Time{}); err != nil {
+		t.Fatalf("Failed to commit: %s", err)
+	}
+
+	st, err := m.Status("a")
+	if err != nil {
+		t.Fatalf("Failed to get status: %s", err)
+	}
+
+	if st.BytesToday != 0 || st.ConcurrentUploads != 0 || st.ActiveStorage != 10 {
+		t.Fatalf("Unexpected status: %+v", st)
+	}
+
+	if err := m.Reserve("a", "svr/3", 100); err != nil {
+		t.Fatalf("Failed to reserve: %s", err)
+	}
+
+	if err := m.Reserve("a", "svr/4", 1); !errors.Is(err, quota.ErrExceeded) {
+		t.Fatalf("Expected daily quota to be exceeded, got: %v", err)
+	}
+}
+
+func TestQuotaForget(t *testing.T) {
+	cfg := &config.QuotaConfig{}
+	cfg.ActiveStorage.UnmarshalText([]byte("100B"))
+
+	m := quota.NewManager(cfg, store.NewMemory(), time.Hour)
+
+	if err := m.Commit("a", "svr/1", 80, time.Time{}); err != nil {
+		t.Fatalf("Failed to commit: %s", err)
+	}
+
+	if err := m.Reserve("a", "svr/2", 40); !errors.Is(err, quota.ErrExceeded) {
+		t.Fatalf("Expected active storage quota to be exceeded, got: %v", err)
+	}
+
+	if err := m.Forget("a", "svr/1"); err != nil {
+		t.Fatalf("Failed to forget: %s", err)
+	}
+
+	if err := m.Reserve("a", "svr/2", 40); err != nil {
+		t.Fatalf("Failed to reserve after deletion: %s", err)
+	}
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// pruneInterval is the number of requests after which idle buckets are removed.
+const pruneInterval = 1000
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Memory is a limiter keeping its buckets in memory.
+// The limits are enforced per replica.
+type Memory struct {
+	rate  float64
+	burst int
+
+	buckets map[string]*bucket
+	count   int
+	mu      sync.Mutex
+
+	now func() time.Time
+}
+
+// NewMemory creates a new in-memory limiter which allows rate requests
+// per second with bursts of up to burst requests.
+func NewMemory(rate float64, burst int) *Memory {
+	return &Memory{
+		rate:    rate,
+		burst:   burst,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow consumes a token from the bucket of key.
+func (m *Memory) Allow(key string) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	if m.count++; m.count%pruneInterval == 0 {
+		m.prune(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens: float64(m.burst),
+			last:   now,
+		}
+		m.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(m.burst), b.tokens+now.Sub(b.last).Seconds()*m.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / m.rate * float64(time.Second))
+		return false, wait, nil
+	}
+
+	b.tokens--
+
+	return true, 0, nil
+}
+
+// prune removes buckets which have been refilled completely.
+func (m *Memory) prune(now time.Time) {
+	full := time.Duration(float64(m.burst) / m.rate * float64(time.Second))
+
+	for k, b := range m.buckets {
+		if now.Sub(b.last) > full {
+			delete(m.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimit_test
+
+import (
+	"testing"
+
+	"github.com/stv0g/gose/pkg/ratelimit"
+)
+
+func TestMemory(t *testing.T) {
+	l := ratelimit.NewMemory(0.1, 2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _, err := l.Allow("a"); err != nil || !ok {
+			t.Fatalf("Request %d within burst was rejected", i)
+		}
+	}
+
+	ok, retryAfter, err := l.Allow("a")
+	if err != nil || ok {
+		t.Fatal("Request exceeding burst was allowed")
+	}
+
+	if retryAfter <= 0 {
+		t.Fatalf("Invalid retry after: %s", retryAfter)
+	}
+
+	// Other clients have their own bucket.
+	if ok, _, err := l.Allow("b"); err != nil || !ok {
+		t.Fatal("Request of other client was rejected")
+	}
+}
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ratelimit implements token-bucket rate limiting for the API routes.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stv0g/gose/pkg/logging"
+)
+
+// Limiter decides whether a request identified by key is allowed.
+type Limiter interface {
+	// Allow consumes a token from the bucket of key.
+	// If no token is available, it returns false and the time after which a token will be available.
+	Allow(key string) (bool, time.Duration, error)
+}
+
+// Middleware rejects requests exceeding the limit with 429 Too Many Requests.
+// Clients are identified by their IP address within a group of routes.
+// Requests are passed if the limiter fails.
+func Middleware(l Limiter, group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, retryAfter, err := l.Allow(group + ":" + c.ClientIP())
+		if err != nil {
+			logging.FromContext(c).Error("Failed to check rate limit", "error", err)
+		} else if !ok {
+			secs := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(secs))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
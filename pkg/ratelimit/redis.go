@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucket atomically refills and consumes a token.
+// We use the Redis server time to be independent of clock skew between replicas.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local b = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(b[1]) or burst
+local last = tonumber(b[2]) or now
+
+tokens = math.min(burst, tokens + (now - last) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = (1 - tokens) / rate
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(now))
+redis.call("EXPIRE", KEYS[1], math.ceil(burst / rate) + 1)
+
+return {allowed, tostring(wait)}
+`)
+
+// Redis is a limiter keeping its buckets in a Redis server.
+// The limits are shared between all replicas using the same server.
+type Redis struct {
+	client *redis.Client
+	rate   float64
+	burst  int
+}
+
+// NewRedis creates a new limiter backed by the Redis server at url.
+func NewRedis(url string, rate float64, burst int) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Redis{
+		client: redis.NewClient(opts),
+		rate:   rate,
+		burst:  burst,
+	}, nil
+}
+
+// Allow consumes a token from the bucket of key.
+func (r *Redis) Allow(key string) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := tokenBucket.Run(ctx, r.client, []string{"gose:ratelimit:" + key}, r.rate, r.burst).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	allowed, _ := res[0].(int64)
+	wait, _ := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+
+	return allowed == 1, time.Duration(wait * float64(time.Second)), nil
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimit_test
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/stv0g/gose/pkg/ratelimit"
+)
+
+func TestRedis(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	l, err := ratelimit.NewRedis("redis://"+s.Addr(), 0.1, 2)
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if ok, _, err := l.Allow("a"); err != nil || !ok {
+			t.Fatalf("Request %d within burst was rejected: %v", i, err)
+		}
+	}
+
+	ok, retryAfter, err := l.Allow("a")
+	if err != nil || ok {
+		t.Fatalf("Request exceeding burst was allowed: %v", err)
+	}
+
+	if retryAfter <= 0 {
+		t.Fatalf("Invalid retry after: %s", retryAfter)
+	}
+
+	// Other clients have their own bucket.
+	if ok, _, err := l.Allow("b"); err != nil || !ok {
+		t.Fatalf("Request of other client was rejected: %v", err)
+	}
+
+	// Buckets expire once they would be full again.
+	if ttl := s.TTL("gose:ratelimit:a"); ttl <= 0 {
+		t.Fatalf("Bucket has no expiry: %s", ttl)
+	}
+
+	s.Close()
+	if _, _, err := l.Allow("a"); err == nil {
+		t.Fatal("Expected error for unreachable server")
+	}
+}
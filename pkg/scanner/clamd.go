@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package scanner scans uploads for malware using a ClamAV daemon.
+package scanner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the chunks streamed to clamd.
+const chunkSize = 1 << 16
+
+// ErrScan is returned if clamd failed to scan the stream.
+var ErrScan = errors.New("scan failed")
+
+// Result is the outcome of a scan.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Clamd is a client for the ClamAV daemon.
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd creates a new client for a clamd listening on a TCP ("tcp") or Unix ("unix") socket.
+func NewClamd(network, address string, timeout time.Duration) *Clamd {
+	return &Clamd{
+		network: network,
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// Ping checks if clamd is reachable.
+func (c *Clamd) Ping() error {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return err
+	}
+
+	if reply = strings.TrimRight(reply, "\x00"); reply != "PONG" {
+		return fmt.Errorf("unexpected reply: %s", reply)
+	}
+
+	return nil
+}
+
+// Scan streams the contents of r to clamd using the INSTREAM command.
+// The timeout applies to each individual network operation rather than the complete scan.
+func (c *Clamd) Scan(r io.Reader) (*Result, error) {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	write := func(b []byte) error {
+		conn.SetWriteDeadline(time.Now().Add(c.timeout))
+		_, err := conn.Write(b)
+		return err
+	}
+
+	if err := write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if err := write(buf[:4+n]); err != nil {
+				return nil, err
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	// A zero-length chunk terminates the stream.
+	if err := write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(c.timeout))
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return parseReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseReply parses replies like "stream: OK" or "stream: Eicar-Signature FOUND".
+func parseReply(reply string) (*Result, error) {
+	_, status, ok := strings.Cut(reply, ": ")
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected reply: %s", ErrScan, reply)
+	}
+
+	switch {
+	case status == "OK":
+		return &Result{}, nil
+
+	case strings.HasSuffix(status, " FOUND"):
+		return &Result{
+			Infected:  true,
+			Signature: strings.TrimSuffix(status, " FOUND"),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrScan, status)
+	}
+}
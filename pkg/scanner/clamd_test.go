@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package scanner_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stv0g/gose/pkg/scanner"
+)
+
+// fakeClamd implements the PING and INSTREAM commands of clamd.
+// Streams containing the word "virus" are reported as infected.
+func fakeClamd(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				rd := bufio.NewReader(conn)
+
+				cmd, err := rd.ReadString(0)
+				if err != nil {
+					return
+				}
+
+				switch cmd {
+				case "zPING\x00":
+					conn.Write([]byte("PONG\x00"))
+
+				case "zINSTREAM\x00":
+					var data bytes.Buffer
+					for {
+						var l uint32
+						if err := binary.Read(rd, binary.BigEndian, &l); err != nil {
+							return
+						}
+
+						if l == 0 {
+							break
+						}
+
+						if _, err := io.CopyN(&data, rd, int64(l)); err != nil {
+							return
+						}
+					}
+
+					if strings.Contains(data.String(), "virus") {
+						conn.Write([]byte("stream: Fake-Virus FOUND\x00"))
+					} else {
+						conn.Write([]byte("stream: OK\x00"))
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return l
+}
+
+func TestClamd(t *testing.T) {
+	l := fakeClamd(t)
+	defer l.Close()
+
+	c := scanner.NewClamd("tcp", l.Addr().String(), time.Second)
+
+	if err := c.Ping(); err != nil {
+		t.Fatalf("Failed to ping: %s", err)
+	}
+
+	// Larger than a single chunk.
+	clean := strings.Repeat("clean", 100000)
+
+	res, err := c.Scan(strings.NewReader(clean))
+	if err != nil {
+		t.Fatalf("Failed to scan: %s", err)
+	}
+
+	if res.Infected {
+		t.Fatal("Clean stream reported as infected")
+	}
+
+	res, err = c.Scan(strings.NewReader(clean + "virus"))
+	if err != nil {
+		t.Fatalf("Failed to scan: %s", err)
+	}
+
+	if !res.Infected || res.Signature != "Fake-Virus" {
+		t.Fatalf("Infected stream not detected: %+v", res)
+	}
+}
@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+const (
+	// TagKey is the object tag in which the scan status is stored.
+	TagKey = "scan"
+
+	StatusPending  = "pending"
+	StatusClean    = "clean"
+	StatusInfected = "infected"
+	StatusSkipped  = "skipped"
+	StatusError    = "error"
+
+	// ActionDelete removes infected objects.
+	ActionDelete = "delete"
+
+	// ActionQuarantine keeps infected objects for inspection but blocks their download.
+	ActionQuarantine = "quarantine"
+)
+
+// Scanner scans objects after their upload has been completed.
+type Scanner struct {
+	*Clamd
+
+	config *config.ScannerConfig
+}
+
+// NewScanner creates a new scanner.
+func NewScanner(cfg *config.ScannerConfig) *Scanner {
+	return &Scanner{
+		Clamd:  NewClamd(cfg.Network, cfg.Address, cfg.Timeout),
+		config: cfg,
+	}
+}
+
+// ScanObject streams an object to clamd and tags it with the result.
+// Infected objects are deleted or quarantined depending on the configured action.
+func (s *Scanner) ScanObject(ctx context.Context, svr server.Server, key string) (string, *Result, error) {
+	obj, err := svr.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(svr.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return StatusError, nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer obj.Body.Close()
+
+	if max := int64(s.config.MaxSize); max > 0 && aws.Int64Value(obj.ContentLength) > max {
+		return StatusSkipped, nil, s.tag(ctx, svr, key, StatusSkipped)
+	}
+
+	res, err := s.Scan(obj.Body)
+	if err != nil {
+		if err := s.tag(ctx, svr, key, StatusError); err != nil {
+			return StatusError, nil, err
+		}
+
+		return StatusError, nil, err
+	}
+
+	if !res.Infected {
+		return StatusClean, res, s.tag(ctx, svr, key, StatusClean)
+	}
+
+	if s.config.Action == ActionDelete {
+		if _, err := svr.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(svr.Config.Bucket),
+			Key:    aws.String(key),
+		}); err != nil {
+			return StatusInfected, res, fmt.Errorf("failed to delete infected object: %w", err)
+		}
+
+		return StatusInfected, res, nil
+	}
+
+	return StatusInfected, res, s.tag(ctx, svr, key, StatusInfected)
+}
+
+func (s *Scanner) tag(ctx context.Context, svr server.Server, key, status string) error {
+	return svr.SetTags(ctx, key, map[string]string{
+		TagKey: status,
+	})
+}
+
+// DownloadAllowed returns true if an object with the given scan status can be downloaded.
+// Objects which have been uploaded before scanning was enabled have no status.
+func DownloadAllowed(status string) bool {
+	switch status {
+	case "", StatusClean, StatusSkipped:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumSHA512 = "sha512"
+
+	// ChecksumTagPrefix is the prefix of the object tags in which we store the checksums.
+	ChecksumTagPrefix = "checksum-"
+)
+
+// NewChecksumHash returns a new hash for the given checksum algorithm.
+func NewChecksumHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumSHA512:
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm: %s", algo)
+	}
+}
+
+// ComputeChecksums streams an object from the S3 server and calculates its digests
+// for the given algorithms. The results are stored as object tags.
+//
+// We can not rely on the checksums calculated by S3 itself as those are
+// composite checksums of the individual parts of a multi-part upload.
+func (s *Server) ComputeChecksums(ctx context.Context, key string, algos []string) (map[string]string, error) {
+	hashes := map[string]hash.Hash{}
+	writers := []io.Writer{}
+	for _, algo := range algos {
+		h, err := NewChecksumHash(algo)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes[algo] = h
+		writers = append(writers, h)
+	}
+
+	obj, err := s.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer obj.Body.Close()
+
+	if _, err := io.Copy(io.MultiWriter(writers...), obj.Body); err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+
+	sums := map[string]string{}
+	tags := map[string]string{}
+	for algo, h := range hashes {
+		sum := hex.EncodeToString(h.Sum(nil))
+
+		sums[algo] = sum
+		tags[ChecksumTagPrefix+algo] = sum
+	}
+
+	if err := s.SetTags(ctx, key, tags); err != nil {
+		return nil, fmt.Errorf("failed to tag object: %w", err)
+	}
+
+	return sums, nil
+}
+
+// GetChecksums returns the previously calculated checksums of an object.
+func (s *Server) GetChecksums(ctx context.Context, key string) (map[string]string, error) {
+	tags, err := s.GetTags(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ChecksumsFromTags(tags), nil
+}
+
+// ChecksumsFromTags extracts the checksums from a set of object tags.
+func ChecksumsFromTags(tags map[string]string) map[string]string {
+	sums := map[string]string{}
+	for k, v := range tags {
+		if algo, ok := strings.CutPrefix(k, ChecksumTagPrefix); ok {
+			sums[algo] = v
+		}
+	}
+
+	return sums
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server_test
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/server"
+)
+
+func TestChecksums(t *testing.T) {
+	const (
+		sha256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+		sha512 = "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043"
+	)
+
+	svr, tags := newTagStub(t, "key", "hello", map[string]string{"expiration": "1day"})
+
+	sums, err := svr.ComputeChecksums(context.Background(), "key", []string{server.ChecksumSHA256, server.ChecksumSHA512})
+	if err != nil {
+		t.Fatalf("Failed to compute checksums: %s", err)
+	}
+
+	want := map[string]string{
+		server.ChecksumSHA256: sha256,
+		server.ChecksumSHA512: sha512,
+	}
+
+	if !maps.Equal(sums, want) {
+		t.Fatalf("Unexpected checksums: %v, want %v", sums, want)
+	}
+
+	// The checksums are added to the existing tags.
+	if got := tags(); got["expiration"] != "1day" || got[server.ChecksumTagPrefix+server.ChecksumSHA256] != sha256 {
+		t.Fatalf("Unexpected tags: %v", got)
+	}
+
+	if sums, err := svr.GetChecksums(context.Background(), "key"); err != nil || !maps.Equal(sums, want) {
+		t.Fatalf("Unexpected checksums: %v, %v", sums, err)
+	}
+
+	if _, err := svr.ComputeChecksums(context.Background(), "key", []string{"md4"}); err == nil {
+		t.Fatal("Expected error for unsupported algorithm")
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// MaxCopySize is the maximum size of an object which can be copied by a single CopyObject request.
+const MaxCopySize = 5 << 30 // 5GiB
+
+// SameEndpoint returns true if the buckets of both servers are accessible by the same endpoint and credentials.
+// Only then objects can be copied on the server-side.
+func (s *Server) SameEndpoint(o Server) bool {
+	return s.Config.Endpoint == o.Config.Endpoint &&
+		s.Config.Region == o.Config.Region &&
+		s.Config.AccessKey == o.Config.AccessKey
+}
+
+// CopyTo copies an object including its meta-data and tags to the same key at another server.
+// The passed tags are merged into the existing ones and may be nil.
+// Objects are copied on the server-side if possible and streamed part by part otherwise.
+func (s *Server) CopyTo(ctx context.Context, dst Server, key string, tags map[string]string) error {
+	head, err := s.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get object: %w", err)
+	}
+
+	allTags, err := s.GetTags(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	maps.Copy(allTags, tags)
+
+	if s.SameEndpoint(dst) && aws.Int64Value(head.ContentLength) <= MaxCopySize {
+		in := &s3.CopyObjectInput{
+			Bucket:            aws.String(dst.Config.Bucket),
+			Key:               aws.String(key),
+			CopySource:        aws.String(s.Config.Bucket + "/" + key),
+			MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+		}
+
+		// Copies are encrypted according to the settings of the destination.
+		in.ServerSideEncryption, in.SSEKMSKeyId = dst.ServerSideEncryption()
+
+		if _, err := dst.CopyObjectWithContext(ctx, in); err != nil {
+			return fmt.Errorf("failed to copy object: %w", err)
+		}
+	} else if err := s.streamTo(ctx, dst, key, head); err != nil {
+		return err
+	}
+
+	if len(allTags) > 0 {
+		if err := dst.SetTags(ctx, key, allTags); err != nil {
+			return fmt.Errorf("failed to tag object: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// streamTo copies an object by downloading and uploading it with the part size of the source server.
+// Only a single part is kept in memory at a time.
+func (s *Server) streamTo(ctx context.Context, dst Server, key string, head *s3.HeadObjectOutput) error {
+	in := &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(dst.Config.Bucket),
+		Key:         aws.String(key),
+		Metadata:    head.Metadata,
+		ContentType: head.ContentType,
+	}
+
+	// Copies to servers using SSE-C are not supported.
+	dst.Encrypt(in, nil)
+
+	mpu, err := dst.CreateMultipartUploadWithContext(ctx, in)
+	if err != nil {
+		return fmt.Errorf("failed to initiate upload: %w", err)
+	}
+
+	parts, err := s.streamParts(ctx, dst, key, aws.StringValue(mpu.UploadId), aws.Int64Value(head.ContentLength))
+	if err == nil {
+		_, err = dst.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:   aws.String(dst.Config.Bucket),
+			Key:      aws.String(key),
+			UploadId: mpu.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{
+				Parts: parts,
+			},
+		})
+	}
+
+	if err != nil {
+		if abortErr := dst.AbortUpload(ctx, key, aws.StringValue(mpu.UploadId)); abortErr != nil {
+			return fmt.Errorf("failed to stream object: %w (abort failed: %s)", err, abortErr)
+		}
+
+		return fmt.Errorf("failed to stream object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) streamParts(ctx context.Context, dst Server, key, uploadID string, size int64) ([]*s3.CompletedPart, error) {
+	partSize := int64(s.Config.PartSize)
+	buf := make([]byte, min(partSize, size))
+	parts := []*s3.CompletedPart{}
+
+	for num, off := int64(1), int64(0); off < size; num, off = num+1, off+partSize {
+		n := min(partSize, size-off)
+
+		obj, err := s.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.Config.Bucket),
+			Key:    aws.String(key),
+			Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+n-1)),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = io.ReadFull(obj.Body, buf[:n])
+		obj.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		part, err := dst.UploadPartWithContext(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(dst.Config.Bucket),
+			Key:           aws.String(key),
+			UploadId:      aws.String(uploadID),
+			PartNumber:    aws.Int64(num),
+			ContentLength: aws.Int64(n),
+			Body:          bytes.NewReader(buf[:n]),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, &s3.CompletedPart{
+			PartNumber: aws.Int64(num),
+			ETag:       part.ETag,
+		})
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/config"
+)
+
+const copyKey = "5d41402abc4b2a76b9719d911017c592"
+
+// copyStub serves the source object and records the encryption headers of requests creating the copy.
+type copyStub struct {
+	mu         sync.Mutex
+	encryption map[string]string
+	kmsKeyID   map[string]string
+}
+
+func (s *copyStub) handle(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodHead && r.URL.Path == "/src/"+copyKey:
+		w.Header().Set("Content-Length", "5")
+		w.Header().Set("Content-Type", "text/plain")
+
+	case r.Method == http.MethodGet && q.Has("tagging"):
+		io.WriteString(w, `<Tagging><TagSet></TagSet></Tagging>`)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/src/"+copyKey:
+		io.WriteString(w, "hello")
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.record("copy", r)
+		io.WriteString(w, `<CopyObjectResult><ETag>"`+copyKey+`"</ETag></CopyObjectResult>`)
+
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		s.record("stream", r)
+		io.WriteString(w, `<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>`)
+
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		w.Header().Set("ETag", `"`+copyKey+`"`)
+
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		io.WriteString(w, `<CompleteMultipartUploadResult><ETag>"`+copyKey+`"</ETag></CompleteMultipartUploadResult>`)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *copyStub) record(path string, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.encryption[path] = r.Header.Get("X-Amz-Server-Side-Encryption")
+	s.kmsKeyID[path] = r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+}
+
+func TestCopyToEncryption(t *testing.T) {
+	stub := &copyStub{
+		encryption: map[string]string{},
+		kmsKeyID:   map[string]string{},
+	}
+
+	endpoint := newS3Stub(t, stub.handle)
+	otherEndpoint := newS3Stub(t, stub.handle)
+
+	src := newTestServer(endpoint, "src", "src", nil)
+
+	// Copied on the server-side.
+	dst := newTestServer(endpoint, "dst", "dst", &config.EncryptionConfig{
+		Mode: config.EncryptionSSES3,
+	})
+
+	if err := src.CopyTo(context.Background(), dst, copyKey, nil); err != nil {
+		t.Fatalf("Failed to copy object: %s", err)
+	}
+
+	// Streamed part by part.
+	other := newTestServer(otherEndpoint, "other", "dst", &config.EncryptionConfig{
+		Mode:     config.EncryptionSSEKMS,
+		KMSKeyID: "my-key",
+	})
+
+	if err := src.CopyTo(context.Background(), other, copyKey, nil); err != nil {
+		t.Fatalf("Failed to stream object: %s", err)
+	}
+
+	if enc := stub.encryption["copy"]; enc != s3.ServerSideEncryptionAes256 {
+		t.Fatalf("Unexpected encryption of copied object: %q", enc)
+	}
+
+	if enc, id := stub.encryption["stream"], stub.kmsKeyID["stream"]; enc != s3.ServerSideEncryptionAwsKms || id != "my-key" {
+		t.Fatalf("Unexpected encryption of streamed object: %q, %q", enc, id)
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/config"
+)
+
+// CustomerKeySize is the size of the keys used for SSE-C.
+const CustomerKeySize = 32
+
+// ErrInvalidCustomerKey is returned for malformed SSE-C keys.
+var ErrInvalidCustomerKey = errors.New("invalid encryption key")
+
+// EncryptionMode returns the configured server-side encryption mode or an empty string.
+func (s *Server) EncryptionMode() string {
+	if s.Config.Encryption == nil {
+		return ""
+	}
+
+	return s.Config.Encryption.Mode
+}
+
+// UsesCustomerKeys returns true if objects are encrypted with keys provided by the client (SSE-C).
+func (s *Server) UsesCustomerKeys() bool {
+	return s.EncryptionMode() == config.EncryptionSSEC
+}
+
+// ETagIsMD5 returns true if the ETags of objects and parts are MD5 digests of their content.
+// This is not the case for objects encrypted with SSE-KMS or SSE-C.
+func (s *Server) ETagIsMD5() bool {
+	switch s.EncryptionMode() {
+	case config.EncryptionSSEKMS, config.EncryptionSSEC:
+		return false
+	default:
+		return true
+	}
+}
+
+// Encrypt sets the encryption settings for a new multi-part upload.
+// The key is only used for SSE-C and ignored otherwise.
+func (s *Server) Encrypt(in *s3.CreateMultipartUploadInput, key []byte) {
+	in.ServerSideEncryption, in.SSEKMSKeyId = s.ServerSideEncryption()
+
+	if s.UsesCustomerKeys() {
+		in.SSECustomerAlgorithm, in.SSECustomerKey = CustomerKey(key)
+	}
+}
+
+// ServerSideEncryption returns the SSE-S3 or SSE-KMS parameters of requests which create objects.
+// Both are nil for other modes.
+func (s *Server) ServerSideEncryption() (*string, *string) {
+	switch s.EncryptionMode() {
+	case config.EncryptionSSES3:
+		return aws.String(s3.ServerSideEncryptionAes256), nil
+
+	case config.EncryptionSSEKMS:
+		if id := s.Config.Encryption.KMSKeyID; id != "" {
+			return aws.String(s3.ServerSideEncryptionAwsKms), aws.String(id)
+		}
+
+		return aws.String(s3.ServerSideEncryptionAwsKms), nil
+	}
+
+	return nil, nil
+}
+
+// CustomerKey returns the SSE-C algorithm and key parameters of S3 requests.
+// Both are nil if no key is passed. The AWS SDK adds the MD5 digest of the key itself.
+func CustomerKey(key []byte) (*string, *string) {
+	if key == nil {
+		return nil, nil
+	}
+
+	return aws.String(s3.ServerSideEncryptionAes256), aws.String(string(key))
+}
+
+// NewCustomerKey generates a random SSE-C key.
+func NewCustomerKey() ([]byte, error) {
+	key := make([]byte, CustomerKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// EncodeCustomerKey encodes a SSE-C key for the use in URLs.
+func EncodeCustomerKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeCustomerKey decodes a SSE-C key from a URL.
+func DecodeCustomerKey(s string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(key) != CustomerKeySize {
+		return nil, ErrInvalidCustomerKey
+	}
+
+	return key, nil
+}
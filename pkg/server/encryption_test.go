@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+func TestCustomerKey(t *testing.T) {
+	key, err := server.NewCustomerKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+
+	enc := server.EncodeCustomerKey(key)
+
+	dec, err := server.DecodeCustomerKey(enc)
+	if err != nil {
+		t.Fatalf("Failed to decode key: %s", err)
+	}
+
+	if !bytes.Equal(key, dec) {
+		t.Fatal("Decoded key mismatch")
+	}
+
+	if _, err := server.DecodeCustomerKey(enc[:10]); err == nil {
+		t.Fatal("Expected error for truncated key")
+	}
+
+	if algo, k := server.CustomerKey(nil); algo != nil || k != nil {
+		t.Fatal("Expected no parameters without a key")
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	svr := server.Server{
+		Config: &config.S3Server{
+			Encryption: &config.EncryptionConfig{
+				Mode:     config.EncryptionSSEKMS,
+				KMSKeyID: "my-key",
+			},
+		},
+	}
+
+	in := &s3.CreateMultipartUploadInput{}
+	svr.Encrypt(in, nil)
+
+	if aws.StringValue(in.ServerSideEncryption) != s3.ServerSideEncryptionAwsKms || aws.StringValue(in.SSEKMSKeyId) != "my-key" {
+		t.Fatalf("Unexpected encryption settings: %v", in)
+	}
+
+	if svr.ETagIsMD5() {
+		t.Fatal("ETags of SSE-KMS objects are no MD5 digests")
+	}
+}
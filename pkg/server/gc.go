@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+// StaleUploads returns the incomplete multi-part uploads which have been initiated before the given time.
+func (s *Server) StaleUploads(ctx context.Context, before time.Time) ([]Upload, error) {
+	uploads, err := s.ListUploads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stale := []Upload{}
+	for _, u := range uploads {
+		if u.Initiated.Before(before) {
+			stale = append(stale, u)
+		}
+	}
+
+	return stale, nil
+}
+
+// ExpiredObjects returns the keys of uploaded objects whose expiration class has passed at the given time.
+// These are usually deleted by the lifecycle rules of the bucket.
+// But not all S3 implementations support them.
+func (s *Server) ExpiredObjects(ctx context.Context, now time.Time) ([]string, error) {
+	if len(s.Config.Expiration) == 0 {
+		return nil, nil
+	}
+
+	// Objects younger than the shortest expiration class can not have expired yet.
+	// So we can skip retrieving their tags.
+	minDays := s.Config.Expiration[0].Days
+	for _, cls := range s.Config.Expiration {
+		minDays = min(minDays, cls.Days)
+	}
+
+	expired := []string{}
+
+	var errTags error
+	if err := s.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Config.Bucket),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			key := aws.StringValue(obj.Key)
+			modified := aws.TimeValue(obj.LastModified)
+
+			// Skip GoSƐ's own state and foreign objects.
+			if !utils.IsValidETag(key) || modified.AddDate(0, 0, int(minDays)).After(now) {
+				continue
+			}
+
+			tags, err := s.GetTags(ctx, key)
+			if err != nil {
+				errTags = err
+				return false
+			}
+
+			if cls := s.GetExpirationClass(tags["expiration"]); cls != nil && modified.AddDate(0, 0, int(cls.Days)).Before(now) {
+				expired = append(expired, key)
+			}
+		}
+
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	if errTags != nil {
+		return nil, errTags
+	}
+
+	return expired, nil
+}
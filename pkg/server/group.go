@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sort"
+)
+
+// ErrNoHealthyServer is returned if all servers of a group are unhealthy.
+var ErrNoHealthyServer = errors.New("no healthy server available")
+
+// Group returns the servers of a group ordered by their ID.
+func (sl List) Group(name string) []Server {
+	svrs := []Server{}
+	for _, svr := range sl {
+		if svr.Config.Group == name {
+			svrs = append(svrs, svr)
+		}
+	}
+
+	sort.Slice(svrs, func(i, j int) bool {
+		return svrs[i].Config.ID < svrs[j].Config.ID
+	})
+
+	return svrs
+}
+
+// Select picks a server of a group for a new upload.
+// Among the healthy servers with the highest priority, one is chosen randomly according to their weights.
+func (sl List) Select(group string, healthy func(Server) bool) (Server, error) {
+	var candidates []Server
+	var total int
+
+	for _, svr := range sl.Group(group) {
+		if !healthy(svr) {
+			continue
+		}
+
+		if len(candidates) > 0 && svr.Config.Priority < candidates[0].Config.Priority {
+			continue
+		} else if len(candidates) > 0 && svr.Config.Priority > candidates[0].Config.Priority {
+			candidates = nil
+			total = 0
+		}
+
+		candidates = append(candidates, svr)
+		total += svr.Config.Weight
+	}
+
+	if len(candidates) == 0 {
+		return Server{}, ErrNoHealthyServer
+	}
+
+	if total <= 0 {
+		return candidates[0], nil
+	}
+
+	n := rand.IntN(total)
+	for _, svr := range candidates {
+		if n -= svr.Config.Weight; n < 0 {
+			return svr, nil
+		}
+	}
+
+	return candidates[len(candidates)-1], nil
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+func groupServer(id, group string, priority, weight int) server.Server {
+	svr := &config.S3Server{}
+	svr.ID = id
+	svr.Group = group
+	svr.Priority = priority
+	svr.Weight = weight
+
+	return server.Server{Config: svr}
+}
+
+func TestSelect(t *testing.T) {
+	sl := server.List{
+		"primary":   groupServer("primary", "eu", 10, 1),
+		"secondary": groupServer("secondary", "eu", 0, 1),
+		"tertiary":  groupServer("tertiary", "eu", 0, 3),
+		"other":     groupServer("other", "us", 10, 1),
+	}
+
+	down := map[string]bool{}
+	healthy := func(svr server.Server) bool {
+		return !down[svr.Config.ID]
+	}
+
+	for i := 0; i < 10; i++ {
+		if svr, err := sl.Select("eu", healthy); err != nil || svr.Config.ID != "primary" {
+			t.Fatalf("Expected server with highest priority, got %v, %v", svr.Config, err)
+		}
+	}
+
+	down["primary"] = true
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		svr, err := sl.Select("eu", healthy)
+		if err != nil {
+			t.Fatalf("Failed to select server: %s", err)
+		}
+
+		counts[svr.Config.ID]++
+	}
+
+	if counts["primary"] != 0 || counts["other"] != 0 {
+		t.Errorf("Selected unhealthy or foreign server: %v", counts)
+	}
+
+	if counts["tertiary"] < 2*counts["secondary"] {
+		t.Errorf("Selection does not respect weights: %v", counts)
+	}
+
+	down["secondary"] = true
+	down["tertiary"] = true
+
+	if _, err := sl.Select("eu", healthy); !errors.Is(err, server.ErrNoHealthyServer) {
+		t.Errorf("Expected error, got %v", err)
+	}
+}
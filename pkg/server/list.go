@@ -6,9 +6,12 @@ package server
 import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/logging"
+	"github.com/stv0g/gose/pkg/tracing"
 )
 
 // List is a list of servers.
@@ -22,14 +25,26 @@ func NewList(svrs []config.S3Server) List {
 	for i := range svrs {
 		svr := &svrs[i]
 
+		svc := s3.New(sess, &aws.Config{
+			Region:           aws.String(svr.Region),
+			Endpoint:         aws.String(svr.Endpoint),
+			S3ForcePathStyle: aws.Bool(svr.PathStyle),
+			DisableSSL:       aws.Bool(svr.NoSSL),
+			Credentials:      credentials.NewStaticCredentials(svr.AccessKey, svr.SecretKey, ""),
+		})
+
+		// Pass the ID of the originating request to the S3 server for correlating its logs.
+		// Presigned requests have no context and are thus not affected.
+		svc.Handlers.Build.PushBack(func(r *request.Request) {
+			if id := logging.RequestID(r.Context()); id != "" {
+				r.HTTPRequest.Header.Set(logging.RequestIDHeader, id)
+			}
+		})
+
+		tracing.InstrumentS3(&svc.Handlers, svr.ID)
+
 		svcs[svr.ID] = Server{
-			S3: s3.New(sess, &aws.Config{
-				Region:           aws.String(svr.Region),
-				Endpoint:         aws.String(svr.Endpoint),
-				S3ForcePathStyle: aws.Bool(svr.PathStyle),
-				DisableSSL:       aws.Bool(svr.NoSSL),
-				Credentials:      credentials.NewStaticCredentials(svr.AccessKey, svr.SecretKey, ""),
-			}),
+			S3:     svc,
 			Config: svr,
 		}
 	}
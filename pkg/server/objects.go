@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Object describes an uploaded file including its meta-data and tags.
+type Object struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	LastModified time.Time         `json:"last_modified"`
+	ContentType  string            `json:"content_type"`
+	Metadata     map[string]string `json:"metadata"`
+	Tags         map[string]string `json:"tags"`
+
+	// CustomerKey is set for objects encrypted with SSE-C.
+	// Their meta-data is not accessible without the key.
+	CustomerKey bool `json:"customer_key,omitempty"`
+}
+
+// Upload describes an incomplete multi-part upload.
+type Upload struct {
+	Key       string    `json:"key"`
+	UploadID  string    `json:"upload_id"`
+	Initiated time.Time `json:"initiated"`
+}
+
+// IsNotFound returns true if an S3 request failed because the object or configuration does not exist.
+func IsNotFound(err error) bool {
+	var aerr awserr.RequestFailure
+	return errors.As(err, &aerr) && aerr.StatusCode() == http.StatusNotFound
+}
+
+// GetObjectInfo returns the meta-data and tags of an object.
+func (s *Server) GetObjectInfo(ctx context.Context, key string) (*Object, error) {
+	head, customerKey, err := s.stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := s.GetTags(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		Key:          key,
+		Size:         aws.Int64Value(head.ContentLength),
+		LastModified: aws.TimeValue(head.LastModified),
+		ContentType:  aws.StringValue(head.ContentType),
+		Metadata:     aws.StringValueMap(head.Metadata),
+		Tags:         tags,
+		CustomerKey:  customerKey,
+	}, nil
+}
+
+// StatObject returns the meta-data of an object.
+// Objects encrypted with SSE-C can not be inspected without their key.
+// Only their size and modification time are returned.
+func (s *Server) StatObject(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+	head, _, err := s.stat(ctx, key)
+	return head, err
+}
+
+func (s *Server) stat(ctx context.Context, key string) (*s3.HeadObjectOutput, bool, error) {
+	head, err := s.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil || !s.UsesCustomerKeys() || IsNotFound(err) {
+		return head, false, err
+	}
+
+	// S3 rejects HEAD requests for objects encrypted with SSE-C without their key.
+	// But the bucket listing still includes them.
+	resp, err := s.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.Config.Bucket),
+		Prefix:  aws.String(key),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(resp.Contents) == 0 || aws.StringValue(resp.Contents[0].Key) != key {
+		return nil, false, awserr.NewRequestFailure(awserr.New("NotFound", "object not found", nil), http.StatusNotFound, "")
+	}
+
+	obj := resp.Contents[0]
+
+	return &s3.HeadObjectOutput{
+		ContentLength: obj.Size,
+		LastModified:  obj.LastModified,
+		ETag:          obj.ETag,
+		Metadata:      map[string]*string{},
+	}, true, nil
+}
+
+// ListObjectKeys returns a page of object keys and the token for the next page.
+// An empty token is returned for the last page.
+func (s *Server) ListObjectKeys(ctx context.Context, token string, limit int64) ([]string, string, error) {
+	in := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.Config.Bucket),
+		MaxKeys: aws.Int64(limit),
+	}
+
+	if token != "" {
+		in.ContinuationToken = aws.String(token)
+	}
+
+	resp, err := s.ListObjectsV2WithContext(ctx, in)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys := []string{}
+	for _, obj := range resp.Contents {
+		keys = append(keys, *obj.Key)
+	}
+
+	return keys, aws.StringValue(resp.NextContinuationToken), nil
+}
+
+// ListUploads returns all incomplete multi-part uploads.
+func (s *Server) ListUploads(ctx context.Context) ([]Upload, error) {
+	uploads := []Upload{}
+
+	if err := s.ListMultipartUploadsPagesWithContext(ctx, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s.Config.Bucket),
+	}, func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+		for _, u := range page.Uploads {
+			uploads = append(uploads, Upload{
+				Key:       aws.StringValue(u.Key),
+				UploadID:  aws.StringValue(u.UploadId),
+				Initiated: aws.TimeValue(u.Initiated),
+			})
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	return uploads, nil
+}
+
+// DeleteObjectByKey removes an object from the bucket.
+func (s *Server) DeleteObjectByKey(ctx context.Context, key string) error {
+	_, err := s.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
+// AbortUpload aborts an incomplete multi-part upload.
+func (s *Server) AbortUpload(ctx context.Context, key, uploadID string) error {
+	_, err := s.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.Config.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	return err
+}
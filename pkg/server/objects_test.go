@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+func TestGetObjectInfoCustomerKey(t *testing.T) {
+	const key = "d41d8cd98f00b204e9800998ecf8427e"
+
+	endpoint := newS3Stub(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		// S3 requires the key of SSE-C objects for HEAD requests.
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusBadRequest)
+
+		case r.URL.Path == "/bucket" && r.URL.Query().Get("list-type") == "2":
+			if r.URL.Query().Get("prefix") != key {
+				io.WriteString(w, `<ListBucketResult></ListBucketResult>`)
+				return
+			}
+
+			io.WriteString(w, `<ListBucketResult><Contents><Key>`+key+`</Key><Size>42</Size><LastModified>2024-01-01T00:00:00Z</LastModified></Contents></ListBucketResult>`)
+
+		case r.URL.Path == "/bucket/"+key && r.URL.Query().Has("tagging"):
+			io.WriteString(w, `<Tagging><TagSet><Tag><Key>expiration</Key><Value>1day</Value></Tag></TagSet></Tagging>`)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	svr := newTestServer(endpoint, "s1", "bucket", &config.EncryptionConfig{Mode: config.EncryptionSSEC})
+
+	obj, err := svr.GetObjectInfo(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Failed to get object: %s", err)
+	}
+
+	if !obj.CustomerKey || obj.Size != 42 || obj.Tags["expiration"] != "1day" {
+		t.Fatalf("Unexpected object: %+v", obj)
+	}
+
+	if _, err := svr.GetObjectInfo(context.Background(), "00000000000000000000000000000000"); !server.IsNotFound(err) {
+		t.Fatalf("Expected not found error, got: %v", err)
+	}
+}
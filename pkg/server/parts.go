@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	units "github.com/docker/go-units"
+)
+
+var (
+	ErrMaxUploadSizeExceeded = errors.New("max upload size exceeded")
+	ErrInvalidPartLayout     = errors.New("invalid part layout")
+)
+
+// ListAllParts returns all uploaded parts of a multi-part upload.
+// In contrast to ListParts, it follows the pagination of the S3 API.
+func (s *Server) ListAllParts(ctx context.Context, key, uploadID string) ([]*s3.Part, error) {
+	parts := []*s3.Part{}
+
+	if err := s.ListPartsPagesWithContext(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(s.Config.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, lastPage bool) bool {
+		parts = append(parts, page.Parts...)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+// CheckPartLayout validates the uploaded parts of a multi-part upload against the server's limits.
+// All parts except the last one must have the configured part size and
+// the total size must not exceed the maximum upload size.
+func (s *Server) CheckPartLayout(parts []*s3.Part) error {
+	var total int64
+
+	partSize := int64(s.Config.PartSize)
+	maxSize := int64(s.Config.MaxUploadSize)
+
+	if len(parts) == 0 {
+		return fmt.Errorf("%w: no parts uploaded", ErrInvalidPartLayout)
+	}
+
+	for i, p := range parts {
+		num := aws.Int64Value(p.PartNumber)
+		sz := aws.Int64Value(p.Size)
+
+		if num != int64(i+1) {
+			return fmt.Errorf("%w: part %d is missing", ErrInvalidPartLayout, i+1)
+		}
+
+		last := i == len(parts)-1
+		if (!last && sz != partSize) || (last && (sz <= 0 || sz > partSize)) {
+			return fmt.Errorf("%w: part %d has size %d but part size is %d", ErrInvalidPartLayout, num, sz, partSize)
+		}
+
+		if total += sz; total > maxSize {
+			return fmt.Errorf("%w: limit is %s", ErrMaxUploadSizeExceeded, units.HumanSize(float64(maxSize)))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+func parts(sizes ...int64) []*s3.Part {
+	ps := []*s3.Part{}
+	for i, sz := range sizes {
+		ps = append(ps, &s3.Part{
+			PartNumber: aws.Int64(int64(i + 1)),
+			Size:       aws.Int64(sz),
+		})
+	}
+	return ps
+}
+
+func TestCheckPartLayout(t *testing.T) {
+	svr := server.Server{
+		Config: &config.S3Server{},
+	}
+
+	svr.Config.PartSize = 10
+	svr.Config.MaxUploadSize = 25
+
+	if err := svr.CheckPartLayout(parts(10, 10, 5)); err != nil {
+		t.Fatalf("Valid layout rejected: %s", err)
+	}
+
+	for _, ps := range [][]*s3.Part{
+		parts(),
+		parts(10, 5, 5),
+		parts(10, 11),
+		append(parts(10), &s3.Part{PartNumber: aws.Int64(3), Size: aws.Int64(1)}),
+	} {
+		if err := svr.CheckPartLayout(ps); !errors.Is(err, server.ErrInvalidPartLayout) {
+			t.Fatalf("Expected invalid layout error, got: %v", err)
+		}
+	}
+
+	if err := svr.CheckPartLayout(parts(10, 10, 10)); !errors.Is(err, server.ErrMaxUploadSizeExceeded) {
+		t.Fatalf("Expected max upload size error, got: %v", err)
+	}
+}
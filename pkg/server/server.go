@@ -4,6 +4,7 @@
 package server
 
 import (
+	"context"
 	"net/url"
 	"strings"
 
@@ -92,12 +93,17 @@ func (s *Server) DetectImplementation() string {
 	}
 }
 
-// Healthy returns true if the S3 server is reachable and responds to our authenticated requests.
-func (s *Server) Healthy() bool {
-	_, err := s.S3.ListObjects(&s3.ListObjectsInput{
+// Component is the name of the server in health reports.
+func (s *Server) Component() string {
+	return "server:" + s.Config.ID
+}
+
+// Check returns an error if the S3 server is not reachable or does not accept our authenticated requests.
+func (s *Server) Check(ctx context.Context) error {
+	_, err := s.S3.ListObjectsWithContext(ctx, &s3.ListObjectsInput{
 		Bucket:  aws.String(s.Config.Bucket),
 		MaxKeys: aws.Int64(0),
 	})
 
-	return err == nil
+	return err
 }
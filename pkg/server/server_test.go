@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+// newS3Stub starts a server handling S3 requests by h and returns its endpoint.
+func newS3Stub(t *testing.T, h http.HandlerFunc) string {
+	s := httptest.NewServer(h)
+	t.Cleanup(s.Close)
+
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// newTestServer returns a server for a bucket at the given endpoint.
+func newTestServer(endpoint, id, bucket string, enc *config.EncryptionConfig) server.Server {
+	svrs := server.NewList([]config.S3Server{
+		{
+			S3ServerConfig: config.S3ServerConfig{
+				ID:       id,
+				PartSize: config.MinPartSize,
+			},
+			Endpoint:   endpoint,
+			Bucket:     bucket,
+			Region:     "us-east-1",
+			PathStyle:  true,
+			NoSSL:      true,
+			AccessKey:  "access",
+			SecretKey:  "secret",
+			Encryption: enc,
+		},
+	})
+
+	return svrs[id]
+}
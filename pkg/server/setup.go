@@ -4,28 +4,20 @@
 package server
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/utils"
 )
 
 // Setup initializes the S3 bucket (life-cycle rules & CORS).
 func (s *Server) Setup() error {
-	if s.Config.Implementation == "" {
-		s.Config.Implementation = s.DetectImplementation()
-		log.Printf("Detected %s S3 implementation for server %s", s.Config.Implementation, s.GetURL())
-	} else {
-		log.Printf("Using %s S3 implementation for server %s", s.Config.Implementation, s.GetURL())
-	}
-
-	// MinIO does not support the setup of bucket CORS rules and MPU abortion lifecycle.
-	if s.Config.Implementation == ImplementationMinio {
-		s.Config.Setup.CORS = false
-		s.Config.Setup.AbortIncompleteUploads = 0
-	}
+	s.prepare()
 
 	// Create bucket if it does not exist yet.
 	if _, err := s.GetBucketPolicy(&s3.GetBucketPolicyInput{
@@ -42,20 +34,10 @@ func (s *Server) Setup() error {
 
 	// Set CORS configuration for bucket.
 	if s.Config.Setup.CORS {
-		corsRule := &s3.CORSRule{
-			AllowedHeaders: aws.StringSlice([]string{"Authorization"}),
-			AllowedOrigins: aws.StringSlice([]string{"*"}),
-			MaxAgeSeconds:  aws.Int64(3000),
-			AllowedMethods: aws.StringSlice([]string{"PUT", "GET"}),
-			ExposeHeaders:  aws.StringSlice([]string{"ETag"}),
-		}
-
 		if _, err := s.PutBucketCors(&s3.PutBucketCorsInput{
 			Bucket: aws.String(s.Config.Bucket),
 			CORSConfiguration: &s3.CORSConfiguration{
-				CORSRules: []*s3.CORSRule{
-					corsRule,
-				},
+				CORSRules: s.corsRules(),
 			},
 		}); err != nil {
 			return fmt.Errorf("failed to set bucket %s's CORS rules: %w", s.Config.Bucket, err)
@@ -63,39 +45,7 @@ func (s *Server) Setup() error {
 	}
 
 	if s.Config.Setup.Lifecycle {
-		// Create lifecycle policies.
-		lcRules := []*s3.LifecycleRule{}
-
-		if s.Config.Setup.AbortIncompleteUploads > 0 {
-			lcRules = append(lcRules, &s3.LifecycleRule{
-				ID:     aws.String("Abort Multipart Uploads"),
-				Status: aws.String("Enabled"),
-				AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{
-					DaysAfterInitiation: aws.Int64(31),
-				},
-				Filter: &s3.LifecycleRuleFilter{
-					Prefix: aws.String("/"),
-				},
-			})
-		}
-
-		for _, cls := range s.Config.Expiration {
-			lcRules = append(lcRules, &s3.LifecycleRule{
-				ID:     aws.String(fmt.Sprintf("Expiration after %s", cls.Title)),
-				Status: aws.String("Enabled"),
-				Filter: &s3.LifecycleRuleFilter{
-					Tag: &s3.Tag{
-						Key:   aws.String("expiration"),
-						Value: aws.String(cls.ID),
-					},
-				},
-				Expiration: &s3.LifecycleExpiration{
-					Days: aws.Int64(cls.Days),
-				},
-			})
-		}
-
-		if len(lcRules) > 0 {
+		if lcRules := s.lifecycleRules(); len(lcRules) > 0 {
 			if _, err := s.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
 				Bucket: aws.String(s.Config.Bucket),
 				LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
@@ -109,3 +59,119 @@ func (s *Server) Setup() error {
 
 	return nil
 }
+
+// SetupDiff returns the changes of the CORS and lifecycle rules which Setup would apply.
+// It returns no lines if nothing would be changed.
+func (s *Server) SetupDiff(ctx context.Context) ([]string, error) {
+	s.prepare()
+
+	diff := []string{}
+
+	if s.Config.Setup.CORS {
+		var current []*s3.CORSRule
+		if resp, err := s.GetBucketCorsWithContext(ctx, &s3.GetBucketCorsInput{
+			Bucket: aws.String(s.Config.Bucket),
+		}); err == nil {
+			current = resp.CORSRules
+		} else if !IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get bucket %s's CORS rules: %w", s.Config.Bucket, err)
+		}
+
+		diff = append(diff, diffRules("CORS rules", current, s.corsRules())...)
+	}
+
+	if s.Config.Setup.Lifecycle {
+		if desired := s.lifecycleRules(); len(desired) > 0 {
+			var current []*s3.LifecycleRule
+			if resp, err := s.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{
+				Bucket: aws.String(s.Config.Bucket),
+			}); err == nil {
+				current = resp.Rules
+			} else if !IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get bucket %s's lifecycle rules: %w", s.Config.Bucket, err)
+			}
+
+			diff = append(diff, diffRules("Lifecycle rules", current, desired)...)
+		}
+	}
+
+	return diff, nil
+}
+
+// prepare detects the S3 implementation and disables the setup steps which it does not support.
+func (s *Server) prepare() {
+	if s.Config.Implementation == "" {
+		s.Config.Implementation = s.DetectImplementation()
+		slog.Info("Detected S3 implementation", "server", s.Config.ID, "url", s.GetURL().String(), "implementation", s.Config.Implementation)
+	} else {
+		slog.Info("Using S3 implementation", "server", s.Config.ID, "url", s.GetURL().String(), "implementation", s.Config.Implementation)
+	}
+
+	// MinIO does not support the setup of bucket CORS rules and MPU abortion lifecycle.
+	if s.Config.Implementation == ImplementationMinio {
+		s.Config.Setup.CORS = false
+		s.Config.Setup.AbortIncompleteUploads = 0
+	}
+}
+
+func (s *Server) corsRules() []*s3.CORSRule {
+	return []*s3.CORSRule{
+		{
+			AllowedHeaders: aws.StringSlice([]string{
+				"Authorization", "Content-MD5", "x-amz-checksum-sha256",
+				"x-amz-server-side-encryption-customer-algorithm",
+				"x-amz-server-side-encryption-customer-key",
+				"x-amz-server-side-encryption-customer-key-MD5",
+			}),
+			AllowedOrigins: aws.StringSlice([]string{"*"}),
+			MaxAgeSeconds:  aws.Int64(3000),
+			AllowedMethods: aws.StringSlice([]string{"PUT", "GET"}),
+			ExposeHeaders:  aws.StringSlice([]string{"ETag"}),
+		},
+	}
+}
+
+func (s *Server) lifecycleRules() []*s3.LifecycleRule {
+	lcRules := []*s3.LifecycleRule{}
+
+	if s.Config.Setup.AbortIncompleteUploads > 0 {
+		lcRules = append(lcRules, &s3.LifecycleRule{
+			ID:     aws.String("Abort Multipart Uploads"),
+			Status: aws.String("Enabled"),
+			AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: aws.Int64(31),
+			},
+			Filter: &s3.LifecycleRuleFilter{
+				Prefix: aws.String("/"),
+			},
+		})
+	}
+
+	for _, cls := range s.Config.Expiration {
+		lcRules = append(lcRules, &s3.LifecycleRule{
+			ID:     aws.String(fmt.Sprintf("Expiration after %s", cls.Title)),
+			Status: aws.String("Enabled"),
+			Filter: &s3.LifecycleRuleFilter{
+				Tag: &s3.Tag{
+					Key:   aws.String("expiration"),
+					Value: aws.String(cls.ID),
+				},
+			},
+			Expiration: &s3.LifecycleExpiration{
+				Days: aws.Int64(cls.Days),
+			},
+		})
+	}
+
+	return lcRules
+}
+
+// diffRules returns a diff of the rules prefixed by a header or nothing if they are equal.
+func diffRules(name string, current, desired any) []string {
+	a, b := awsutil.Prettify(current), awsutil.Prettify(desired)
+	if a == b {
+		return nil
+	}
+
+	return append([]string{name + ":"}, utils.DiffLines(a, b)...)
+}
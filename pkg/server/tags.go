@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// GetTags returns the tags of an object as a map.
+func (s *Server) GetTags(ctx context.Context, key string) (map[string]string, error) {
+	resp, err := s.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, t := range resp.TagSet {
+		tags[*t.Key] = *t.Value
+	}
+
+	return tags, nil
+}
+
+// SetTags merges the passed tags into the existing tag set of an object.
+// S3 replaces the whole tag set on every PutObjectTagging request.
+// So we need to fetch the existing tags first in order to not lose them.
+func (s *Server) SetTags(ctx context.Context, key string, tags map[string]string) error {
+	existing, err := s.GetTags(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range tags {
+		existing[k] = v
+	}
+
+	tagSet := []*s3.Tag{}
+	for k, v := range existing {
+		tagSet = append(tagSet, &s3.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(v),
+		})
+	}
+
+	_, err = s.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+		Tagging: &s3.Tagging{
+			TagSet: tagSet,
+		},
+	})
+
+	return err
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package server_test
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"maps"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/server"
+)
+
+type tagging struct {
+	Tags []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"TagSet>Tag"`
+}
+
+// newTagStub returns a server for a bucket with a single object whose tags are kept in memory.
+func newTagStub(t *testing.T, key, body string, tags map[string]string) (server.Server, func() map[string]string) {
+	var mu sync.Mutex
+
+	endpoint := newS3Stub(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.URL.Path != "/bucket/"+key:
+			w.WriteHeader(http.StatusNotFound)
+
+		case r.URL.Query().Has("tagging") && r.Method == http.MethodPut:
+			var in tagging
+			if err := xml.NewDecoder(r.Body).Decode(&in); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			tags = map[string]string{}
+			for _, t := range in.Tags {
+				tags[t.Key] = t.Value
+			}
+
+		case r.URL.Query().Has("tagging"):
+			io.WriteString(w, `<Tagging><TagSet>`)
+			for k, v := range tags {
+				io.WriteString(w, `<Tag><Key>`+k+`</Key><Value>`+v+`</Value></Tag>`)
+			}
+			io.WriteString(w, `</TagSet></Tagging>`)
+
+		case r.Method == http.MethodGet:
+			io.WriteString(w, body)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	return newTestServer(endpoint, "s1", "bucket", nil), func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return maps.Clone(tags)
+	}
+}
+
+func TestSetTags(t *testing.T) {
+	svr, tags := newTagStub(t, "key", "", map[string]string{"a": "1", "b": "2"})
+
+	if err := svr.SetTags(context.Background(), "key", map[string]string{"b": "3", "c": "4"}); err != nil {
+		t.Fatalf("Failed to set tags: %s", err)
+	}
+
+	// Existing tags are kept unless they are replaced.
+	if got, want := tags(), map[string]string{"a": "1", "b": "3", "c": "4"}; !maps.Equal(got, want) {
+		t.Fatalf("Unexpected tags: %v, want %v", got, want)
+	}
+
+	if err := svr.SetTags(context.Background(), "missing", map[string]string{"a": "1"}); err == nil {
+		t.Fatal("Expected error for missing object")
+	}
+}
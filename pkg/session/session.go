@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package session implements encrypted tokens which bind an upload to its uploader.
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("malformed session token")
+	ErrSignature = errors.New("invalid session signature")
+	ErrExpired   = errors.New("session expired")
+	ErrType      = errors.New("wrong type of session token")
+)
+
+// Session describes an upload which is in progress.
+type Session struct {
+	Server string `json:"svr"`
+	ETag   string `json:"etag"`
+
+	// Content is the ETag of the uploaded content as calculated by the client.
+	// It differs from the ETag of the object for uploads encrypted with SSE-C, which get a random one.
+	Content string `json:"cnt,omitempty"`
+
+	UploadID string    `json:"uid"`
+	Identity string    `json:"id"`
+	Expires  time.Time `json:"exp"`
+
+	// Key is the SSE-C key of the upload.
+	// Tokens are encrypted. So the key is not disclosed if a token leaks, e.g. from the local storage of a browser.
+	Key []byte `json:"key,omitempty"`
+
+	// Owner marks tokens which prove the ownership of a completed upload instead of an upload in progress.
+	Owner bool `json:"own,omitempty"`
+}
+
+// Signer issues and verifies session tokens.
+// Tokens are encrypted and authenticated with AES-256-GCM using a key derived from the secret.
+type Signer struct {
+	aead     cipher.AEAD
+	validity time.Duration
+}
+
+// NewSigner creates a new signer.
+// All replicas of GoSƐ must share the same secret.
+func NewSigner(secret []byte, validity time.Duration) *Signer {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte("gose session token"))
+
+	// Both can not fail for a key of 32 bytes.
+	block, _ := aes.NewCipher(h.Sum(nil))
+	aead, _ := cipher.NewGCM(block)
+
+	return &Signer{
+		aead:     aead,
+		validity: validity,
+	}
+}
+
+// RandomSecret generates a new random secret.
+func RandomSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// Issue returns a token for a new session.
+// The key is only set for uploads encrypted with SSE-C.
+func (s *Signer) Issue(server, etag, content, uploadID, identity string, key []byte) (string, error) {
+	return s.Sign(&Session{
+		Server:   server,
+		ETag:     etag,
+		Content:  content,
+		UploadID: uploadID,
+		Identity: identity,
+		Key:      key,
+		Expires:  time.Now().Add(s.validity).Truncate(time.Second),
+	})
+}
+
+// IssueOwner returns a token for the owner of a completed upload.
+// It is valid until the upload expires or forever if expires is zero.
+func (s *Signer) IssueOwner(server, etag string, expires time.Time) (string, error) {
+	return s.Sign(&Session{
+		Server:  server,
+		ETag:    etag,
+		Owner:   true,
+		Expires: expires.Truncate(time.Second),
+	})
+}
+
+// Sign returns an encrypted token for the session.
+func (s *Signer) Sign(sess *Session) (string, error) {
+	payload, err := json.Marshal(sess)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(payload)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(s.aead.Seal(nonce, nonce, payload, nil)), nil
+}
+
+// Verify decrypts a token of an upload in progress and checks its expiry and returns the session.
+func (s *Signer) Verify(token string) (*Session, error) {
+	sess, err := s.open(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if sess.Owner {
+		return nil, ErrType
+	}
+
+	return sess, nil
+}
+
+// VerifyOwner decrypts a token issued by IssueOwner and checks its expiry and returns the session.
+func (s *Signer) VerifyOwner(token string) (*Session, error) {
+	sess, err := s.open(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sess.Owner {
+		return nil, ErrType
+	}
+
+	return sess, nil
+}
+
+func (s *Signer) open(token string) (*Session, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) < s.aead.NonceSize()+s.aead.Overhead() {
+		return nil, ErrMalformed
+	}
+
+	n := s.aead.NonceSize()
+
+	payload, err := s.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return nil, ErrSignature
+	}
+
+	sess := &Session{}
+	if err := json.Unmarshal(payload, sess); err != nil {
+		return nil, ErrMalformed
+	}
+
+	if !sess.Expires.IsZero() && time.Now().After(sess.Expires) {
+		return nil, ErrExpired
+	}
+
+	return sess, nil
+}
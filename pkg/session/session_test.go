@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stv0g/gose/pkg/session"
+)
+
+func TestSession(t *testing.T) {
+	s := session.NewSigner([]byte("secret"), time.Hour)
+
+	token, err := s.Issue("server", "etag", "content", "upload-id", "1.2.3.4", nil)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %s", err)
+	}
+
+	sess, err := s.Verify(token)
+	if err != nil {
+		t.Fatalf("Failed to verify token: %s", err)
+	}
+
+	if sess.Server != "server" || sess.ETag != "etag" || sess.Content != "content" || sess.UploadID != "upload-id" || sess.Identity != "1.2.3.4" {
+		t.Fatalf("Session mismatch: %+v", sess)
+	}
+
+	// Tokens signed with a different secret must be rejected.
+	if _, err := session.NewSigner([]byte("other"), time.Hour).Verify(token); !errors.Is(err, session.ErrSignature) {
+		t.Fatalf("Expected signature error, got: %v", err)
+	}
+
+	if _, err := s.Verify("garbage"); !errors.Is(err, session.ErrMalformed) {
+		t.Fatalf("Expected malformed error, got: %v", err)
+	}
+
+	expired, err := s.Sign(&session.Session{
+		Expires: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("Failed to sign session: %s", err)
+	}
+
+	if _, err := s.Verify(expired); !errors.Is(err, session.ErrExpired) {
+		t.Fatalf("Expected expired error, got: %v", err)
+	}
+}
+
+func TestSessionKey(t *testing.T) {
+	s := session.NewSigner([]byte("secret"), time.Hour)
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	token, err := s.Issue("server", "etag", "content", "upload-id", "1.2.3.4", key)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %s", err)
+	}
+
+	// The key must not be readable from the token.
+	raw, _ := base64.RawURLEncoding.DecodeString(token)
+	if bytes.Contains(raw, key) || bytes.Contains(raw, []byte(base64.StdEncoding.EncodeToString(key))) || bytes.Contains(raw, []byte("etag")) {
+		t.Fatal("Token is not encrypted")
+	}
+
+	sess, err := s.Verify(token)
+	if err != nil {
+		t.Fatalf("Failed to verify token: %s", err)
+	}
+
+	if !bytes.Equal(sess.Key, key) {
+		t.Fatal("Key mismatch")
+	}
+
+	// Tampered tokens must be rejected.
+	raw[len(raw)-1] ^= 1
+	if _, err := s.Verify(base64.RawURLEncoding.EncodeToString(raw)); !errors.Is(err, session.ErrSignature) {
+		t.Fatalf("Expected signature error, got: %v", err)
+	}
+}
+
+func TestSessionOwner(t *testing.T) {
+	s := session.NewSigner([]byte("secret"), time.Hour)
+
+	owner, err := s.IssueOwner("server", "etag", time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to issue token: %s", err)
+	}
+
+	sess, err := s.VerifyOwner(owner)
+	if err != nil {
+		t.Fatalf("Failed to verify token: %s", err)
+	}
+
+	if sess.Server != "server" || sess.ETag != "etag" {
+		t.Fatalf("Session mismatch: %+v", sess)
+	}
+
+	upload, err := s.Issue("server", "etag", "content", "upload-id", "1.2.3.4", nil)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %s", err)
+	}
+
+	// Tokens of uploads in progress and owner tokens must not be interchangeable.
+	if _, err := s.Verify(owner); !errors.Is(err, session.ErrType) {
+		t.Fatalf("Expected type error, got: %v", err)
+	}
+
+	if _, err := s.VerifyOwner(upload); !errors.Is(err, session.ErrType) {
+		t.Fatalf("Expected type error, got: %v", err)
+	}
+
+	expired, err := s.IssueOwner("server", "etag", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to issue token: %s", err)
+	}
+
+	if _, err := s.VerifyOwner(expired); !errors.Is(err, session.ErrExpired) {
+		t.Fatalf("Expected expired error, got: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ package shortener
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -13,7 +14,9 @@ import (
 	"time"
 
 	"github.com/stv0g/gose/pkg/config"
+	"github.com/stv0g/gose/pkg/logging"
 	"github.com/stv0g/gose/pkg/utils"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // Shortener is an URL shortener instance.
@@ -36,7 +39,14 @@ func NewShortener(c *config.ShortenerConfig) (*Shortener, error) {
 	return s, nil
 }
 
-func (s *Shortener) getRequest(u string) (*http.Request, error) {
+func (s *Shortener) client() *http.Client {
+	return &http.Client{
+		Timeout:   time.Second * 10,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+}
+
+func (s *Shortener) getRequest(ctx context.Context, u string) (*http.Request, error) {
 	t := template.New("action")
 
 	var err error
@@ -63,22 +73,56 @@ func (s *Shortener) getRequest(u string) (*http.Request, error) {
 
 	tplURL := tpl.String()
 
-	return http.NewRequest(s.Method, tplURL, nil)
+	req, err := http.NewRequestWithContext(ctx, s.Method, tplURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+
+	return req, nil
+}
+
+// Ping checks if the host of the shortener service is reachable.
+// Any HTTP response is accepted as we do not want to create short links for checks.
+func (s *Shortener) Ping(ctx context.Context) error {
+	req, err := s.getRequest(ctx, "https://example.com")
+	if err != nil {
+		return err
+	}
+
+	u := &url.URL{
+		Scheme: req.URL.Scheme,
+		Host:   req.URL.Host,
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
 }
 
 // Shorten shorten a passed long URL into a short one using the shortener service.
-func (s *Shortener) Shorten(long *url.URL) (*url.URL, error) {
-	req, err := s.getRequest(long.String())
+func (s *Shortener) Shorten(ctx context.Context, long *url.URL) (*url.URL, error) {
+	req, err := s.getRequest(ctx, long.String())
 	if err != nil {
 		return nil, err
 	}
 
-	client := &http.Client{Timeout: time.Second * 10}
-
-	resp, err := client.Do(req)
+	resp, err := s.client().Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("invalid API response: %d: %s", resp.StatusCode, resp.Status)
@@ -102,5 +146,7 @@ func (s *Shortener) Shorten(long *url.URL) (*url.URL, error) {
 		return nil, fmt.Errorf("Unknown shortener response type: %s", s.Response)
 	}
 
+	logging.Logger(ctx).Debug("Shortened URL", "url", long.String(), "short_url", shortURL.String())
+
 	return shortURL, nil
 }
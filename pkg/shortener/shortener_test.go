@@ -4,6 +4,7 @@
 package shortener_test
 
 import (
+	"context"
 	"net/url"
 	"testing"
 
@@ -26,7 +27,7 @@ func TestShortener(t *testing.T) {
 		t.FailNow()
 	}
 
-	short, err := s.Shorten(long)
+	short, err := s.Shorten(context.Background(), long)
 	if err != nil {
 		t.Fatalf("Failed to shorten: %s", err)
 	}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory keeps documents in memory.
+// It is not shared between replicas and mainly useful for testing.
+type Memory struct {
+	docs map[string][]byte
+	mu   sync.RWMutex
+}
+
+// NewMemory creates a new in-memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		docs: map[string][]byte{},
+	}
+}
+
+// Get decodes the document stored under key into v.
+func (m *Memory) Get(key string, v any) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	buf, ok := m.docs[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	return json.Unmarshal(buf, v)
+}
+
+// Put encodes v and stores it under key.
+func (m *Memory) Put(key string, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.docs[key] = buf
+
+	return nil
+}
+
+// Delete removes the document stored under key.
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.docs, key)
+
+	return nil
+}
+
+// List returns the keys of all documents starting with prefix.
+func (m *Memory) List(prefix string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := []string{}
+	for k := range m.docs {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/server"
+)
+
+// S3 stores documents as objects in an S3 bucket.
+type S3 struct {
+	server server.Server
+	prefix string
+}
+
+// NewS3 creates a new store which keeps its documents below prefix in the server's bucket.
+func NewS3(svr server.Server, prefix string) *S3 {
+	return &S3{
+		server: svr,
+		prefix: prefix,
+	}
+}
+
+// Get decodes the document stored under key into v.
+func (s *S3) Get(key string, v any) error {
+	obj, err := s.server.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.server.Config.Bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return ErrNotFound
+		}
+
+		return err
+	}
+	defer obj.Body.Close()
+
+	return json.NewDecoder(obj.Body).Decode(v)
+}
+
+// Put encodes v and stores it under key.
+func (s *S3) Put(key string, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.server.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.server.Config.Bucket),
+		Key:         aws.String(s.prefix + key),
+		Body:        bytes.NewReader(buf),
+		ContentType: aws.String("application/json"),
+	})
+
+	return err
+}
+
+// Delete removes the document stored under key.
+func (s *S3) Delete(key string) error {
+	_, err := s.server.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.server.Config.Bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+
+	return err
+}
+
+// List returns the keys of all documents starting with prefix.
+func (s *S3) List(prefix string) ([]string, error) {
+	keys := []string{}
+
+	if err := s.server.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.server.Config.Bucket),
+		Prefix: aws.String(s.prefix + prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(*obj.Key, s.prefix))
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
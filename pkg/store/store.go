@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package store persists small JSON documents like quotas or block lists.
+package store
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned if a document does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Store is a simple key-value store for JSON documents.
+type Store interface {
+	// Get decodes the document stored under key into v.
+	Get(key string, v any) error
+
+	// Put encodes v and stores it under key.
+	Put(key string, v any) error
+
+	// Delete removes the document stored under key.
+	Delete(key string) error
+
+	// List returns the keys of all documents starting with prefix.
+	List(prefix string) ([]string, error)
+}
+
+// locks serializes read-modify-write cycles of documents within a process.
+var locks sync.Map
+
+// Update loads a document, applies fn and stores it again.
+// A non-existing document is not an error and leaves v untouched.
+// Updates are serialized within the process only. Concurrent updates by
+// other replicas might get lost.
+func Update(s Store, key string, v any, fn func() error) error {
+	l, _ := locks.LoadOrStore(key, &sync.Mutex{})
+	mu := l.(*sync.Mutex)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := s.Get(key, v); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return s.Put(key, v)
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentS3 creates a span for each request of an S3 client.
+// Retries are part of the same span.
+func InstrumentS3(h *request.Handlers, server string) {
+	h.Validate.PushFrontNamed(request.NamedHandler{
+		Name: "gose.tracing.Start",
+		Fn: func(r *request.Request) {
+			// Presigned requests are not sent by us.
+			if r.ExpireTime > 0 {
+				return
+			}
+
+			attrs := []attribute.KeyValue{
+				semconv.RPCSystemKey.String("aws-api"),
+				semconv.RPCService(r.ClientInfo.ServiceID),
+				semconv.RPCMethod(r.Operation.Name),
+				attribute.String("gose.server", server),
+			}
+
+			ctx, _ := Tracer().Start(r.Context(), r.ClientInfo.ServiceID+"."+r.Operation.Name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...))
+
+			r.SetContext(ctx)
+		},
+	})
+
+	h.Complete.PushBackNamed(request.NamedHandler{
+		Name: "gose.tracing.End",
+		Fn: func(r *request.Request) {
+			if r.ExpireTime > 0 {
+				return
+			}
+
+			span := trace.SpanFromContext(r.Context())
+
+			if r.HTTPResponse != nil {
+				span.SetAttributes(semconv.HTTPResponseStatusCode(r.HTTPResponse.StatusCode))
+			}
+
+			span.SetAttributes(attribute.Int("aws.retry_count", r.RetryCount))
+			if id := r.RequestID; id != "" {
+				span.SetAttributes(semconv.AWSRequestID(id))
+			}
+
+			End(span, r.Error)
+		},
+	})
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stv0g/gose/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrumentS3(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	svc := s3.New(session.Must(session.NewSession()), &aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(ts.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("key", "secret", ""),
+		MaxRetries:       aws.Int(0),
+	})
+
+	tracing.InstrumentS3(&svc.Handlers, "test")
+
+	ctx, parent := tracing.Start(context.Background(), "Parent")
+
+	if _, err := svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("key"),
+	}); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	// Presigned requests must not create spans.
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("key"),
+	})
+	if _, err := req.Presign(time.Hour); err != nil {
+		t.Fatalf("Failed to presign: %s", err)
+	}
+
+	tracing.End(parent, nil)
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	s := spans[0]
+	if s.Name() != "S3.HeadObject" {
+		t.Errorf("Unexpected span name: %s", s.Name())
+	}
+
+	if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("Span is not a child of the request span")
+	}
+
+	if s.Status().Code != codes.Error {
+		t.Errorf("Expected error status, got %v", s.Status())
+	}
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"strings"
+)
+
+// DiffLines returns a line-based diff between a and b.
+// Unchanged lines are prefixed by two spaces, removed lines by "- " and added lines by "+ ".
+func DiffLines(a, b string) []string {
+	la := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	lb := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of la[i:] and lb[j:].
+	lcs := make([][]int, len(la)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(lb)+1)
+	}
+
+	for i := len(la) - 1; i >= 0; i-- {
+		for j := len(lb) - 1; j >= 0; j-- {
+			if la[i] == lb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := []string{}
+	i, j := 0, 0
+	for i < len(la) || j < len(lb) {
+		switch {
+		case i < len(la) && j < len(lb) && la[i] == lb[j]:
+			diff = append(diff, "  "+la[i])
+			i++
+			j++
+		case j < len(lb) && (i == len(la) || lcs[i][j+1] >= lcs[i+1][j]):
+			diff = append(diff, "+ "+lb[j])
+			j++
+		default:
+			diff = append(diff, "- "+la[i])
+			i++
+		}
+	}
+
+	return diff
+}
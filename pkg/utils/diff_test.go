@@ -0,0 +1,20 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package utils_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+func TestDiffLines(t *testing.T) {
+	diff := utils.DiffLines("a\nb\nc\n", "a\nc\nd\n")
+	expected := []string{"  a", "- b", "  c", "+ d"}
+
+	if !slices.Equal(diff, expected) {
+		t.Fatalf("Unexpected diff: %q", diff)
+	}
+}
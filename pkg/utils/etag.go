@@ -5,7 +5,9 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -18,6 +20,9 @@ const (
 // IsValidETag check is an ETag is valid as generated/accepted by AWS-S3.
 func IsValidETag(et string) bool {
 	p := strings.SplitN(et, "-", 2)
+	if len(p) != 2 {
+		return false
+	}
 
 	if etag, err := hex.DecodeString(p[0]); err != nil || len(etag) != md5.Size {
 		return false
@@ -29,3 +34,38 @@ func IsValidETag(et string) bool {
 
 	return true
 }
+
+// MultipartETag calculates the ETag of a multi-part upload from the ETags of its parts.
+// The ETag is the MD5 digest of the concatenated binary MD5 digests of the parts
+// suffixed by the number of parts.
+func MultipartETag(partETags []string) (string, error) {
+	h := md5.New()
+
+	for _, pe := range partETags {
+		d, err := hex.DecodeString(strings.Trim(pe, "\""))
+		if err != nil || len(d) != md5.Size {
+			return "", fmt.Errorf("invalid part etag: %s", pe)
+		}
+
+		h.Write(d)
+	}
+
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(partETags)), nil
+}
+
+// RandomETag returns a random ETag with the same number of parts as the passed one.
+// It is used as object key if uploads of the same content must not share an object.
+func RandomETag(etag string) (string, error) {
+	if !IsValidETag(etag) {
+		return "", fmt.Errorf("invalid etag: %s", etag)
+	}
+
+	_, parts, _ := strings.Cut(etag, "-")
+
+	id := make([]byte, md5.Size)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id) + "-" + parts, nil
+}
@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2023 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stv0g/gose/pkg/utils"
+)
+
+func TestMultipartETag(t *testing.T) {
+	etag, err := utils.MultipartETag([]string{
+		"0cc175b9c0f1b6a831c399e269772661",     // md5("a")
+		"\"92eb5ffee6ae2fec3ad71c777531578f\"", // md5("b") quoted as returned by S3
+	})
+	if err != nil {
+		t.Fatalf("Failed to calculate ETag: %s", err)
+	}
+
+	if expected := "96e024ba2074fe77e8e965ba43a704be-2"; etag != expected {
+		t.Fatalf("ETag mismatch: %s != %s", etag, expected)
+	}
+
+	if !utils.IsValidETag(etag) {
+		t.Fatalf("Calculated ETag is invalid: %s", etag)
+	}
+
+	if _, err := utils.MultipartETag([]string{"invalid"}); err == nil {
+		t.Fatal("Expected error for invalid part ETag")
+	}
+}
+
+func TestRandomETag(t *testing.T) {
+	etag := "96e024ba2074fe77e8e965ba43a704be-2"
+
+	r1, err := utils.RandomETag(etag)
+	if err != nil {
+		t.Fatalf("Failed to generate ETag: %s", err)
+	}
+
+	r2, _ := utils.RandomETag(etag)
+
+	if !utils.IsValidETag(r1) || !strings.HasSuffix(r1, "-2") {
+		t.Fatalf("Generated ETag is invalid: %s", r1)
+	}
+
+	if r1 == r2 || r1 == etag {
+		t.Fatal("Generated ETags are not random")
+	}
+
+	if _, err := utils.RandomETag("invalid"); err == nil {
+		t.Fatal("Expected error for invalid ETag")
+	}
+}